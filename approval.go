@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+	"golang.org/x/term"
+)
+
+// stdinIsTerminal reports whether confirmation prompts can be answered
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// byteSize is a flag value for sizes such as 500MB or 2.5GB. Negative means
+// unset
+type byteSize int64
+
+var sizeUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+func (b *byteSize) String() string {
+	if *b < 0 {
+		return ""
+	}
+	return formatSize(int64(*b))
+}
+
+func (b *byteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*b = byteSize(n * multiplier)
+	return nil
+}
+
+// approvalPolicy approves archives without prompting as long as the totals
+// of the run stay within the limits
+type approvalPolicy struct {
+	maxReplace int      // Files replaced, negative for no limit
+	maxWrite   byteSize // Bytes of new and replaced files written, negative for no limit
+	replaced   int
+	written    int64
+}
+
+// active reports whether any limit was set
+func (p *approvalPolicy) active() bool {
+	return p.maxReplace >= 0 || p.maxWrite >= 0
+}
+
+// check decides whether the archive fits in what is left of the limits
+func (p *approvalPolicy) check(summary *takeout.ZipSummary) (bool, string) {
+	replaced := p.replaced + summary.Outdated
+	written := p.written + summary.BytesToWrite + summary.BytesToReplace
+	if p.maxReplace >= 0 && replaced > p.maxReplace {
+		return false, fmt.Sprintf("%d files would be replaced, more than the --max-replace limit of %d", replaced, p.maxReplace)
+	}
+	if p.maxWrite >= 0 && written > int64(p.maxWrite) {
+		return false, fmt.Sprintf("%s would be written, more than the --max-write limit of %s", formatSize(written), p.maxWrite.String())
+	}
+	return true, fmt.Sprintf("within limits, %d files replaced and %s written so far", replaced, formatSize(written))
+}
+
+// approve counts an approved archive towards the limits
+func (p *approvalPolicy) approve(summary *takeout.ZipSummary) {
+	p.replaced += summary.Outdated
+	p.written += summary.BytesToWrite + summary.BytesToReplace
+}
+
+// approvalLog records an approval decision in the extraction log format
+func approvalLog(zipFile, status, reason string) takeout.ExtractionLog {
+	return takeout.ExtractionLog{Archive: zipFile, Path: zipFile, Status: status, Reason: reason, Timestamp: time.Now()}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+func TestByteSizeSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"1024", 1024},
+		{"500MB", 500 << 20},
+		{"2.5 GB", 5 << 29},
+		{"1tb", 1 << 40},
+	}
+	for _, tt := range tests {
+		var b byteSize
+		if err := b.Set(tt.value); err != nil || int64(b) != tt.want {
+			t.Errorf("Set(%q) = %d, %v; want %d", tt.value, b, err, tt.want)
+		}
+	}
+	var b byteSize
+	if err := b.Set("lots"); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+}
+
+func TestApprovalPolicy(t *testing.T) {
+	policy := approvalPolicy{maxReplace: 5, maxWrite: 100}
+	if !policy.active() {
+		t.Fatal("Policy with limits should be active")
+	}
+
+	small := &takeout.ZipSummary{Outdated: 3, BytesToWrite: 60}
+	if ok, reason := policy.check(small); !ok {
+		t.Fatalf("First archive refused: %s", reason)
+	}
+	policy.approve(small)
+
+	// The limits apply to the totals of the run
+	if ok, _ := policy.check(&takeout.ZipSummary{Outdated: 3}); ok {
+		t.Error("Expected refusal above the replace limit")
+	}
+	if ok, _ := policy.check(&takeout.ZipSummary{BytesToWrite: 50}); ok {
+		t.Error("Expected refusal above the write limit")
+	}
+	if ok, _ := policy.check(&takeout.ZipSummary{Outdated: 1, BytesToReplace: 50}); ok {
+		t.Error("Expected replaced files to count towards the write limit")
+	}
+	if ok, _ := policy.check(&takeout.ZipSummary{Outdated: 2, BytesToWrite: 40}); !ok {
+		t.Error("Expected approval at the limits")
+	}
+
+	if (&approvalPolicy{maxReplace: -1, maxWrite: -1}).active() {
+		t.Error("Policy without limits should not be active")
+	}
+}
+
+func TestDeclinedArchiveIsLogged(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("n\n")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	opts := &jobOptions{logFile: filepath.Join(t.TempDir(), "log.csv"), approval: approvalPolicy{maxReplace: -1, maxWrite: -1}}
+	if decision := approveArchive(opts, true, "takeout.zip", &takeout.ZipSummary{}); decision.Status != "Declined" {
+		t.Fatalf("decision = %q, want Declined", decision.Status)
+	}
+	data, err := os.ReadFile(opts.logFile)
+	if err != nil || !strings.Contains(string(data), "Declined") {
+		t.Errorf("log file = %q, %v; want the declined archive", data, err)
+	}
+}
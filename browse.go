@@ -0,0 +1,261 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// browseNode is a file or directory in the merged tree of all archives
+type browseNode struct {
+	name      string
+	path      string // Path within the zip
+	isDir     bool
+	children  map[string]*browseNode
+	size      int64
+	files     int
+	newFiles  int
+	changed   int
+	identical int
+}
+
+func (n *browseNode) child(name string, isDir bool) *browseNode {
+	c, ok := n.children[name]
+	if !ok {
+		path := name
+		if n.path != "" {
+			path = n.path + "/" + name
+		}
+		c = &browseNode{name: name, path: path, isDir: isDir, children: map[string]*browseNode{}}
+		n.children[name] = c
+	}
+	return c
+}
+
+func (n *browseNode) sortedChildren() []*browseNode {
+	children := make([]*browseNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].isDir != children[j].isDir {
+			return children[i].isDir
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// BrowseSelection is the set of zip paths picked in the browser
+type BrowseSelection struct {
+	Includes []string
+	Excludes []string
+}
+
+// buildBrowseTree merges the entries of all archives under the extractor's
+// base path into one tree by zip path, comparing each file against its
+// destination
+func buildBrowseTree(z *takeout.Extractor, zipFiles []string) (*browseNode, error) {
+	root := &browseNode{children: map[string]*browseNode{}}
+	base := z.Options().BasePath
+	if base != "" && base != "." {
+		root.path = filepath.ToSlash(base)
+		root.name = root.path
+	}
+
+	for _, zipFile := range zipFiles {
+		r, err := z.OpenArchive(zipFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", zipFile, err)
+		}
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			destPath, include := z.EntryPath(f.Name)
+			relPath := strings.TrimPrefix(f.Name, root.path+"/")
+			if !include || root.path != "" && relPath == f.Name {
+				continue
+			}
+			addBrowseEntry(z, root, f, relPath, destPath)
+		}
+		r.Close()
+	}
+	return root, nil
+}
+
+func addBrowseEntry(z *takeout.Extractor, root *browseNode, f *zip.File, relPath, destPath string) {
+	var status func(n *browseNode)
+	switch equal, _ := z.IsFileEqual(f, destPath); {
+	case equal:
+		status = func(n *browseNode) { n.identical++ }
+	case takeout.FileExists(destPath):
+		status = func(n *browseNode) { n.changed++ }
+	default:
+		status = func(n *browseNode) { n.newFiles++ }
+	}
+
+	parts := strings.Split(strings.Trim(relPath, "/"), "/")
+	node := root
+	for i, part := range parts {
+		node.size += int64(f.UncompressedSize64)
+		node.files++
+		status(node)
+		node = node.child(part, i < len(parts)-1)
+	}
+	node.size += int64(f.UncompressedSize64)
+	node.files++
+	status(node)
+}
+
+// browser holds the interactive state of BrowseArchives
+type browser struct {
+	root  *browseNode
+	marks map[string]bool // Zip path -> true for include, false for exclude
+	out   io.Writer
+}
+
+// selected reports whether a path is extracted with the current marks, by
+// the same rules as the extractor's filters
+func (b *browser) selected(path string) bool {
+	sel := b.selection()
+	return takeout.MatchesFilters(path, sel.Includes, sel.Excludes)
+}
+
+func (b *browser) show(dir *browseNode) []*browseNode {
+	children := dir.sortedChildren()
+	title := dir.path
+	if title == "" {
+		title = "/"
+	}
+	fmt.Fprintf(b.out, "\n%s  (%d files, %s)\n", title, dir.files, formatSize(dir.size))
+	fmt.Fprintf(b.out, "%4s  %-3s  %8s  %8s  %8s  %10s  %s\n", "#", "sel", "new", "changed", "same", "size", "name")
+	for i, c := range children {
+		mark := "[ ]"
+		if b.selected(c.path) {
+			mark = "[x]"
+		}
+		name := c.name
+		if c.isDir {
+			name += "/"
+		}
+		fmt.Fprintf(b.out, "%4d  %s  %8d  %8d  %8d  %10s  %s\n", i+1, mark, c.newFiles, c.changed, c.identical, formatSize(c.size), name)
+	}
+	fmt.Fprintln(b.out, "Commands: <n> open, .. up, i <n> include, x <n> exclude, r <n> reset, done, quit")
+	return children
+}
+
+// selection turns the marks into extractor filters
+func (b *browser) selection() *BrowseSelection {
+	sel := &BrowseSelection{}
+	for path, include := range b.marks {
+		if include {
+			sel.Includes = append(sel.Includes, path)
+		} else {
+			sel.Excludes = append(sel.Excludes, path)
+		}
+	}
+	sort.Strings(sel.Includes)
+	sort.Strings(sel.Excludes)
+	return sel
+}
+
+// BrowseArchives shows the merged contents of all archives and lets the user
+// include or exclude directories. It returns nil when the user quits
+func BrowseArchives(z *takeout.Extractor, zipFiles []string, in io.Reader, out io.Writer) (*BrowseSelection, error) {
+	root, err := buildBrowseTree(z, zipFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &browser{root: root, marks: map[string]bool{}, out: out}
+	for _, p := range z.Options().Includes {
+		b.marks[p] = true
+	}
+	for _, p := range z.Options().Excludes {
+		b.marks[p] = false
+	}
+
+	stack := []*browseNode{root}
+	scanner := bufio.NewScanner(in)
+	for {
+		dir := stack[len(stack)-1]
+		children := b.show(dir)
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			return nil, scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		pick := func(arg string) *browseNode {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(children) {
+				fmt.Fprintf(out, "No entry %q\n", arg)
+				return nil
+			}
+			return children[n-1]
+		}
+
+		switch fields[0] {
+		case "done":
+			return b.selection(), nil
+		case "quit", "q":
+			return nil, nil
+		case "..":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case "i", "x", "r":
+			if len(fields) < 2 {
+				fmt.Fprintln(out, "Missing entry number")
+				continue
+			}
+			for _, arg := range fields[1:] {
+				node := pick(arg)
+				if node == nil {
+					continue
+				}
+				switch fields[0] {
+				case "i":
+					b.marks[node.path] = true
+				case "x":
+					b.marks[node.path] = false
+				default:
+					delete(b.marks, node.path)
+				}
+			}
+		default:
+			if node := pick(fields[0]); node != nil {
+				if node.isDir {
+					stack = append(stack, node)
+				} else {
+					fmt.Fprintf(out, "%s is a file\n", node.name)
+				}
+			}
+		}
+	}
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+func TestBrowseArchives(t *testing.T) {
+	extractDir := t.TempDir()
+	testTime := time.Now().Round(time.Second)
+	zip1 := createTestZip(t, []testFile{
+		{name: "Takeout/Drive/Docs/a.txt", content: "a", modTime: testTime},
+		{name: "Takeout/Drive/Docs/b.txt", content: "b", modTime: testTime},
+		{name: "Takeout/Drive/Photos/c.jpg", content: "c", modTime: testTime},
+	})
+	zip2 := createTestZip(t, []testFile{
+		{name: "Takeout/Drive/Docs/d.txt", content: "d", modTime: testTime},
+		{name: "Takeout/Mail/All mail.mbox", content: "mail", modTime: testTime},
+	})
+	defer os.Remove(zip1)
+	defer os.Remove(zip2)
+
+	// One identical and one changed file at the destination
+	os.MkdirAll(filepath.Join(extractDir, "Drive", "Docs"), 0755)
+	os.WriteFile(filepath.Join(extractDir, "Drive", "Docs", "a.txt"), []byte("a"), 0644)
+	os.Chtimes(filepath.Join(extractDir, "Drive", "Docs", "a.txt"), testTime, testTime)
+	os.WriteFile(filepath.Join(extractDir, "Drive", "Docs", "b.txt"), []byte("changed"), 0644)
+
+	extractor := takeout.New(takeout.Options{Destination: extractDir, BasePath: "Takeout"})
+
+	root, err := buildBrowseTree(extractor, []string{zip1, zip2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := root.children["Drive"].children["Docs"]
+	if docs.files != 3 || docs.identical != 1 || docs.changed != 1 || docs.newFiles != 1 {
+		t.Errorf("Docs counts = files %d, identical %d, changed %d, new %d; want 3, 1, 1, 1",
+			docs.files, docs.identical, docs.changed, docs.newFiles)
+	}
+	if root.files != 5 {
+		t.Errorf("Root files = %d, want 5", root.files)
+	}
+
+	// Open Drive, include it, then exclude Photos inside it
+	input := strings.NewReader("1\ni 1 2\nx 2\n..\nr 2\ndone\n")
+	var out bytes.Buffer
+	selection, err := BrowseArchives(extractor, []string{zip1, zip2}, input, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection == nil {
+		t.Fatal("Expected a selection")
+	}
+	if got := strings.Join(selection.Includes, ","); got != "Takeout/Drive/Docs" {
+		t.Errorf("Includes = %q, want Takeout/Drive/Docs", got)
+	}
+	if got := strings.Join(selection.Excludes, ","); got != "Takeout/Drive/Photos" {
+		t.Errorf("Excludes = %q, want Takeout/Drive/Photos", got)
+	}
+}
+
+func TestBrowseArchivesQuit(t *testing.T) {
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+
+	extractor := takeout.New(takeout.Options{Destination: t.TempDir()})
+	selection, err := BrowseArchives(extractor, []string{zipPath}, strings.NewReader("quit\n"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection != nil {
+		t.Errorf("Expected nil selection after quit, got %+v", selection)
+	}
+}
+
+func TestBrowserSelectedMatchesFilters(t *testing.T) {
+	// An include nested in an exclude is still excluded, as when extracting
+	b := &browser{marks: map[string]bool{
+		"Takeout/Drive":      false,
+		"Takeout/Drive/Docs": true,
+		"Takeout/Mail":       true,
+	}}
+	files := []string{"Takeout/Drive/Docs/a.txt", "Takeout/Drive/Photos/b.jpg", "Takeout/Mail/All mail.mbox", "Takeout/Calendar/c.ics"}
+	want := map[string]bool{"Takeout/Mail/All mail.mbox": true}
+
+	var entries []testFile
+	for _, name := range files {
+		entries = append(entries, testFile{name: name, content: name})
+	}
+	zipPath := createTestZip(t, entries)
+	defer os.Remove(zipPath)
+
+	extractDir := t.TempDir()
+	sel := b.selection()
+	extractor := takeout.New(takeout.Options{Destination: extractDir, Includes: sel.Includes, Excludes: sel.Excludes})
+	if _, err := extractor.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range files {
+		if got := b.selected(name); got != want[name] {
+			t.Errorf("selected(%q) = %v, want %v", name, got, want[name])
+		}
+		if got := takeout.FileExists(filepath.Join(extractDir, filepath.FromSlash(name))); got != want[name] {
+			t.Errorf("%s extracted = %v, want %v", name, got, want[name])
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the config file picked up from the destination folder
+// when no --config is given
+const configFileName = ".unzip-takeout.yaml"
+
+// envPrefix prefixes the environment variables that override flags, such as
+// UNZIP_TAKEOUT_WORKERS for --workers
+const envPrefix = "UNZIP_TAKEOUT_"
+
+// jobOptions holds the flags of the commands that work on a destination
+type jobOptions struct {
+	configPath      string // Config file the job was read from, given or found at the destination
+	destination     string
+	workers         workerCount
+	maxRate         byteRate
+	maxFileRate     float64
+	throttleHours   scheduleFlag
+	autoMode        bool
+	approval        approvalPolicy
+	dryRun          bool
+	noSymlinks      bool
+	perms           string
+	fileMode        fileMode
+	dirMode         fileMode
+	owner           ownerFlag
+	xattrs          bool
+	minFree         byteSize
+	ignoreSpace     bool
+	basePath        string
+	logFile         string
+	splitMbox       bool
+	splitItems      bool
+	browse          bool
+	includes        stringList
+	excludes        stringList
+	mappings        mappingList
+	nameProfile     string
+	normalize       string
+	charset         string
+	archiveTimezone string
+	compare         string
+	hashThreshold   byteSize
+	hashCache       string
+	prune           bool
+	pruneDelete     bool
+	pruneMaxPercent float64
+	jsonOutput      bool
+	hashAll         bool
+}
+
+// newJobFlags registers the flags that select what goes where, shared by
+// every command that works on a destination
+func newJobFlags(name string, opts *jobOptions) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&opts.configPath, "config", "", "Path to a YAML config file describing the job")
+	flags.StringVar(&opts.basePath, "base-path", "", "Base path within the ZIP file to start extraction from")
+	flags.BoolVar(&opts.splitMbox, "split-mbox", false, "Split .mbox files into per-message .eml files organised by Gmail label")
+	flags.BoolVar(&opts.splitItems, "split-items", false, "Also split .vcf contacts and .ics calendars into one file per contact or event")
+	flags.Var(&opts.includes, "include", "Only extract entries under this path within the ZIP (repeatable)")
+	flags.Var(&opts.excludes, "exclude", "Skip entries under this path within the ZIP (repeatable)")
+	flags.Var(&opts.mappings, "map", "Extract entries under SRC within the ZIP into DEST, as SRC=DEST (repeatable)")
+	flags.StringVar(&opts.nameProfile, "name-profile", "posix", "Filename rules of the destination: posix, windows or exfat")
+	flags.StringVar(&opts.normalize, "normalize", "none", "Unicode form of destination names: none, nfc or nfd")
+	flags.StringVar(&opts.archiveTimezone, "archive-timezone", "auto", "Timezone of DOS timestamps in the archives: auto, UTC, Local, a name such as Europe/Stockholm or an offset such as +02:00")
+	flags.StringVar(&opts.compare, "compare", takeout.DefaultStrategy.String(), "How existing files are compared: size, mtime, crc and sha256 joined by +")
+	opts.hashThreshold = takeout.DefaultHashThreshold
+	flags.Var(&opts.hashThreshold, "hash-threshold", "Only hash files smaller than this size for crc and sha256, or 0 to hash every file")
+	addHashCacheFlag(flags, opts)
+	flags.StringVar(&opts.charset, "charset", "auto", "Charset of entry names stored without the UTF-8 flag: auto, utf-8, cp437, shift-jis or windows-1252")
+	return flags
+}
+
+// newExtractFlags registers the flags of the extract and plan commands
+func newExtractFlags(name string, opts *jobOptions) *flag.FlagSet {
+	flags := newJobFlags(name, opts)
+	addWorkersFlag(flags, opts)
+	flags.BoolVar(&opts.autoMode, "auto", false, "Skip confirmation and auto-start extraction")
+	opts.approval.maxWrite = -1
+	flags.IntVar(&opts.approval.maxReplace, "max-replace", -1, "Approve without prompting unless more than this many files would be replaced")
+	flags.Var(&opts.approval.maxWrite, "max-write", "Approve without prompting unless more than this size (such as 20GB) would be written")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Show extraction details without performing extraction")
+	flags.BoolVar(&opts.noSymlinks, "no-symlinks", false, "Skip symlink entries instead of recreating them")
+	addPermFlags(flags, opts)
+	addThrottleFlags(flags, opts)
+	opts.minFree = 1 << 30
+	flags.Var(&opts.minFree, "min-free", "Free space to keep on the destination filesystem, such as 5GB")
+	flags.BoolVar(&opts.ignoreSpace, "ignore-space", false, "Warn instead of refusing when the destination looks too small")
+	flags.StringVar(&opts.logFile, "log", "", "Path to write extraction logs")
+	flags.BoolVar(&opts.browse, "browse", false, "Interactively browse archive contents and pick folders to extract")
+	flags.BoolVar(&opts.prune, "prune", false, "After extraction, remove destination files that no archive provides")
+	flags.BoolVar(&opts.pruneDelete, "prune-delete", false, "Delete pruned files instead of moving them to a dated trash folder")
+	flags.Float64Var(&opts.pruneMaxPercent, "prune-max-percent", 10, "Abort pruning if more than this percentage of files would be removed (0 for no limit)")
+	return flags
+}
+
+// newCompareFlags registers the flags of the diff and verify commands
+func newCompareFlags(name string, opts *jobOptions) *flag.FlagSet {
+	flags := newJobFlags(name, opts)
+	flags.BoolVar(&opts.jsonOutput, "json", false, "Print the report as JSON")
+	flags.BoolVar(&opts.hashAll, "hash-all", false, "Also compare content hashes of large files")
+	return flags
+}
+
+// knownOption reports whether any command accepts the option name, so a
+// shared config file can hold options only some commands use
+func knownOption(name string) bool {
+	return newExtractFlags("", &jobOptions{}).Lookup(name) != nil ||
+		newCompareFlags("", &jobOptions{}).Lookup(name) != nil
+}
+
+// stringList is a flag value that collects every occurrence of a repeated flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// mappingList collects repeated SRC=DEST mapping flags
+type mappingList []takeout.Mapping
+
+func (m *mappingList) String() string {
+	var parts []string
+	for _, mapping := range *m {
+		parts = append(parts, mapping.Source+"="+mapping.Destination)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *mappingList) Set(value string) error {
+	source, dest, ok := strings.Cut(value, "=")
+	if !ok || dest == "" {
+		return fmt.Errorf("mapping %q is not SRC=DEST", value)
+	}
+	*m = append(*m, takeout.Mapping{Source: source, Destination: dest})
+	return nil
+}
+
+// workerCount is the value of --workers: a number, or auto to pick one by
+// measuring throughput
+type workerCount int
+
+// autoWorkers is the workerCount of --workers=auto
+const autoWorkers workerCount = -1
+
+func (w *workerCount) String() string {
+	if *w == autoWorkers {
+		return "auto"
+	}
+	return strconv.Itoa(int(*w))
+}
+
+func (w *workerCount) Set(value string) error {
+	if strings.EqualFold(strings.TrimSpace(value), "auto") {
+		*w = autoWorkers
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid number of workers %q, want a number or auto", value)
+	}
+	*w = workerCount(n)
+	return nil
+}
+
+// addWorkersFlag registers --workers, which defaults to 4
+func addWorkersFlag(flags *flag.FlagSet, opts *jobOptions) {
+	opts.workers = 4
+	flags.Var(&opts.workers, "workers", "Number of parallel extraction workers, or auto to pick it by measuring throughput")
+}
+
+// fileConfig is a job described in a YAML config file. Any other key sets the
+// flag of the same name, such as workers, log or include
+type fileConfig struct {
+	Destination string          `yaml:"destination"`
+	Archives    []string        `yaml:"archives"`
+	Mappings    []configMapping `yaml:"mappings"`
+	Options     map[string]any  `yaml:",inline"`
+
+	path       string
+	discovered bool // Found in the destination folder rather than given
+}
+
+type configMapping struct {
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+}
+
+// loadConfig reads a config file. Relative paths in it are relative to the
+// folder the file is in
+func loadConfig(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	var cfg fileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	cfg.path = path
+
+	dir := filepath.Dir(path)
+	cfg.Destination = resolveConfigPath(dir, cfg.Destination)
+	for i, archive := range cfg.Archives {
+		cfg.Archives[i] = resolveConfigPath(dir, archive)
+	}
+	for i, m := range cfg.Mappings {
+		if m.Destination == "" {
+			return nil, fmt.Errorf("%s: mapping for %q has no destination", path, m.Source)
+		}
+		cfg.Mappings[i].Destination = resolveConfigPath(dir, m.Destination)
+	}
+	if log, ok := cfg.Options["log"].(string); ok {
+		cfg.Options["log"] = resolveConfigPath(dir, log)
+	}
+	return &cfg, nil
+}
+
+// resolveConfigPath expands a leading ~ and makes relative paths relative to dir
+func resolveConfigPath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// envName returns the environment variable that overrides a flag
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// parseArgs parses flags given anywhere among the positional arguments. Every
+// argument after "--" is positional
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// setFlag sets a flag from an environment or config value. Lists set a
+// repeatable flag once per item
+func setFlag(flags *flag.FlagSet, name string, value any) error {
+	values := []any{value}
+	if list, ok := value.([]any); ok {
+		values = list
+	}
+	for _, v := range values {
+		if err := flags.Set(name, fmt.Sprint(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverrides sets every flag not given on the command line from its
+// environment variable or, failing that, from the config file
+func applyOverrides(flags *flag.FlagSet, given map[string]bool, cfg *fileConfig) error {
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+
+	for _, name := range names {
+		if given[name] || name == "config" {
+			continue
+		}
+		if env, ok := os.LookupEnv(envName(name)); ok {
+			var value any = env
+			switch flags.Lookup(name).Value.(type) {
+			case *stringList, *mappingList:
+				var items []any
+				for _, item := range strings.Split(env, ",") {
+					items = append(items, item)
+				}
+				value = items
+			}
+			if err := setFlag(flags, name, value); err != nil {
+				return fmt.Errorf("%s: %w", envName(name), err)
+			}
+			continue
+		}
+		if cfg == nil {
+			continue
+		}
+		if value, ok := cfg.Options[name]; ok {
+			if err := setFlag(flags, name, value); err != nil {
+				return fmt.Errorf("%s: %s: %w", cfg.path, name, err)
+			}
+		}
+	}
+
+	if cfg != nil {
+		for name := range cfg.Options {
+			if !knownOption(name) || name == "config" {
+				return fmt.Errorf("%s: unknown option %q", cfg.path, name)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveJob parses the command line of a command working on a destination,
+// applies the config file and environment overrides and returns the archives
+// to work on. The destination and mappings end up in opts
+func resolveJob(flags *flag.FlagSet, opts *jobOptions, args []string) ([]string, error) {
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	if opts.configPath == "" {
+		opts.configPath = os.Getenv(envName("config"))
+	}
+	var cfg *fileConfig
+	switch {
+	case opts.configPath != "":
+		if cfg, err = loadConfig(opts.configPath); err != nil {
+			return nil, err
+		}
+	case len(positional) > 0:
+		discovered := filepath.Join(positional[0], configFileName)
+		if _, err := os.Stat(discovered); err == nil {
+			if cfg, err = loadConfig(discovered); err != nil {
+				return nil, err
+			}
+			cfg.discovered = true
+			opts.configPath = discovered
+		}
+	}
+	if err := applyOverrides(flags, given, cfg); err != nil {
+		return nil, err
+	}
+
+	// A config naming its own destination turns every argument into an archive
+	zipFiles := positional
+	if cfg != nil && !cfg.discovered && (cfg.Destination != "" || len(cfg.Mappings) > 0) {
+		opts.destination = cfg.Destination
+	} else if len(positional) > 0 {
+		opts.destination, zipFiles = positional[0], positional[1:]
+	}
+
+	if len(zipFiles) == 0 && cfg != nil {
+		for _, pattern := range cfg.Archives {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", cfg.path, err)
+			}
+			if matches == nil {
+				return nil, fmt.Errorf("%s: no archives match %s", cfg.path, pattern)
+			}
+			zipFiles = append(zipFiles, matches...)
+		}
+	}
+
+	// Relative --map destinations are relative to the destination folder
+	for i, m := range opts.mappings {
+		if !filepath.IsAbs(m.Destination) && opts.destination != "" {
+			opts.mappings[i].Destination = filepath.Join(opts.destination, m.Destination)
+		}
+	}
+	if cfg != nil {
+		for _, m := range cfg.Mappings {
+			opts.mappings = append(opts.mappings, takeout.Mapping{Source: m.Source, Destination: m.Destination})
+		}
+	}
+
+	if _, err := takeout.ParseProfile(opts.nameProfile); err != nil {
+		return nil, err
+	}
+	if _, err := takeout.ParseNormalization(opts.normalize); err != nil {
+		return nil, err
+	}
+	if _, err := takeout.ParseCharset(opts.charset); err != nil {
+		return nil, err
+	}
+	if _, err := takeout.ParsePermPolicy(opts.perms); err != nil {
+		return nil, err
+	}
+	if _, err := takeout.ParseTimezone(opts.archiveTimezone); err != nil {
+		return nil, err
+	}
+	if _, err := takeout.ParseStrategy(opts.compare, int64(opts.hashThreshold)); err != nil {
+		return nil, err
+	}
+	if opts.destination == "" && len(opts.mappings) == 0 {
+		return nil, fmt.Errorf("no destination folder given")
+	}
+	if len(zipFiles) == 0 {
+		return nil, fmt.Errorf("no zip files given")
+	}
+	return zipFiles, nil
+}
+
+// addHashCacheFlag registers the flag choosing where digests of destination
+// files are cached
+func addHashCacheFlag(flags *flag.FlagSet, opts *jobOptions) {
+	flags.StringVar(&opts.hashCache, "hash-cache", "auto", "File to cache digests of destination files in: auto for the user cache folder, or off")
+}
+
+// newHashCache returns the hash cache of the job, or nil when it is off or
+// there is no cache folder
+func (opts *jobOptions) newHashCache() *takeout.HashCache {
+	switch opts.hashCache {
+	case "off":
+		return nil
+	case "", "auto":
+		path, err := takeout.DefaultHashCachePath()
+		if err != nil {
+			return nil
+		}
+		return takeout.NewHashCache(path)
+	}
+	return takeout.NewHashCache(opts.hashCache)
+}
+
+// saveHashCache writes the digests learned during a run. Failing to is not
+// fatal, the files are hashed again next time
+func saveHashCache(opts takeout.Options) {
+	s, ok := opts.Compare.(takeout.Strategy)
+	if !ok || s.Cache == nil {
+		return
+	}
+	if err := s.Cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save hash cache %s: %v\n", s.Cache.Path(), err)
+	}
+}
+
+// extractorOptions returns the engine options for the job. Entries no
+// mapping covers go to the destination folder, if there is one
+func (opts *jobOptions) extractorOptions() takeout.Options {
+	var mappings []takeout.Mapping
+	if len(opts.mappings) > 0 {
+		mappings = append(mappings, opts.mappings...)
+		if opts.destination != "" {
+			mappings = append(mappings, takeout.Mapping{Source: opts.basePath, Destination: opts.destination})
+		}
+	}
+	profile, _ := takeout.ParseProfile(opts.nameProfile)
+	normalize, _ := takeout.ParseNormalization(opts.normalize)
+	charset, _ := takeout.ParseCharset(opts.charset)
+	perms, _ := takeout.ParsePermPolicy(opts.perms)
+	timezone, _ := takeout.ParseTimezone(opts.archiveTimezone)
+	strategy, _ := takeout.ParseStrategy(opts.compare, int64(opts.hashThreshold))
+	strategy.Cache = opts.newHashCache()
+	return takeout.Options{
+		Destination: opts.destination,
+		BasePath:    opts.basePath,
+		Workers:     int(opts.workers),
+		AutoWorkers: opts.workers == autoWorkers,
+		DryRun:      opts.dryRun,
+		NoSymlinks:  opts.noSymlinks,
+		SplitMbox:   opts.splitMbox,
+		SplitItems:  opts.splitItems,
+		Includes:    opts.includes,
+		Excludes:    opts.excludes,
+		Mappings:    mappings,
+		Profile:     profile,
+		Normalize:   normalize,
+		Charset:     charset,
+		Timezone:    timezone,
+		Compare:     strategy,
+		Perms:       perms,
+		FileMode:    os.FileMode(opts.fileMode),
+		DirMode:     os.FileMode(opts.dirMode),
+		Owner:       opts.owner.owner,
+		Metadata:    opts.xattrs,
+		MinFree:     uint64(max(opts.minFree, 0)),
+		Throttle:    opts.newThrottle(),
+	}
+}
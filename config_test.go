@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseArgsInterspersed(t *testing.T) {
+	opts := &jobOptions{}
+	flags := newExtractFlags("extract", opts)
+	positional, err := parseArgs(flags, []string{"dest", "--workers=8", "a.zip", "--auto", "--", "--b.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(positional, " "); got != "dest a.zip --b.zip" {
+		t.Errorf("Positional arguments = %q, want %q", got, "dest a.zip --b.zip")
+	}
+	if opts.workers != 8 || !opts.autoMode {
+		t.Errorf("Flags after arguments not parsed: workers %d, auto %v", opts.workers, opts.autoMode)
+	}
+}
+
+func TestResolveJobConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := `destination: out
+archives:
+  - takeout-*.zip
+workers: 6
+log: extraction.log
+exclude:
+  - Takeout/Mail
+mappings:
+  - source: Takeout/Google Photos
+    destination: photos
+`
+	configPath := filepath.Join(dir, "job.yaml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"takeout-1.zip", "takeout-2.zip"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+
+	t.Setenv("UNZIP_TAKEOUT_WORKERS", "2")
+	t.Setenv("UNZIP_TAKEOUT_BASE_PATH", "Takeout")
+
+	opts := &jobOptions{}
+	zipFiles, err := resolveJob(newExtractFlags("extract", opts), opts, []string{"--config", configPath, "--workers=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zipFiles) != 2 || filepath.Base(zipFiles[0]) != "takeout-1.zip" {
+		t.Errorf("Archives = %v, want both takeout zips", zipFiles)
+	}
+	if opts.destination != filepath.Join(dir, "out") || opts.logFile != filepath.Join(dir, "extraction.log") {
+		t.Errorf("Paths not resolved against the config folder: destination %q, log %q", opts.destination, opts.logFile)
+	}
+	if opts.workers != 3 {
+		t.Errorf("Workers = %d, want the command-line value 3", opts.workers)
+	}
+	if opts.basePath != "Takeout" {
+		t.Errorf("Base path = %q, want the environment value", opts.basePath)
+	}
+	if len(opts.excludes) != 1 || opts.excludes[0] != "Takeout/Mail" {
+		t.Errorf("Excludes = %v, want [Takeout/Mail]", opts.excludes)
+	}
+
+	extractorOpts := opts.extractorOptions()
+	if len(extractorOpts.Mappings) != 2 || extractorOpts.Mappings[0].Destination != filepath.Join(dir, "photos") {
+		t.Errorf("Mappings = %+v, want photos plus the destination", extractorOpts.Mappings)
+	}
+}
+
+func TestResolveJobDiscoversConfig(t *testing.T) {
+	dest := t.TempDir()
+	os.WriteFile(filepath.Join(dest, configFileName), []byte("split-mbox: true\nworkers: 5\n"), 0644)
+
+	opts := &jobOptions{}
+	zipFiles, err := resolveJob(newExtractFlags("extract", opts), opts, []string{dest, "a.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.destination != dest || len(zipFiles) != 1 {
+		t.Errorf("Destination %q, archives %v; want %q and [a.zip]", opts.destination, zipFiles, dest)
+	}
+	if !opts.splitMbox || opts.workers != 5 {
+		t.Errorf("Discovered config not applied: split-mbox %v, workers %d", opts.splitMbox, opts.workers)
+	}
+
+	os.WriteFile(filepath.Join(dest, configFileName), []byte("wrokers: 5\n"), 0644)
+	opts = &jobOptions{}
+	if _, err := resolveJob(newExtractFlags("extract", opts), opts, []string{dest, "a.zip"}); err == nil {
+		t.Error("Expected an error for an unknown config option")
+	}
+}
+
+func TestWorkersFlag(t *testing.T) {
+	opts := &jobOptions{}
+	flags := newExtractFlags("extract", opts)
+	if _, err := parseArgs(flags, []string{"--workers=auto", "dest", "a.zip"}); err != nil {
+		t.Fatal(err)
+	}
+	extractorOpts := opts.extractorOptions()
+	if !extractorOpts.AutoWorkers {
+		t.Error("--workers=auto should pick the number of workers automatically")
+	}
+
+	var w workerCount
+	for _, value := range []string{"0", "-2", "many"} {
+		if err := w.Set(value); err == nil {
+			t.Errorf("--workers=%s should be refused", value)
+		}
+	}
+}
+
+func TestThrottleFlags(t *testing.T) {
+	opts := &jobOptions{}
+	flags := newExtractFlags("extract", opts)
+	if _, err := parseArgs(flags, []string{"--max-rate=50MB/s", "--max-file-rate=20", "--throttle-hours=08:00-18:00", "dest", "a.zip"}); err != nil {
+		t.Fatal(err)
+	}
+	throttle := opts.extractorOptions().Throttle
+	if throttle == nil {
+		t.Fatal("rate flags should set a throttle")
+	}
+	if maxRate, maxFiles := throttle.Limits(); maxRate != 50<<20 || maxFiles != 20 {
+		t.Errorf("limits = %v bytes/s and %v files/s, want 50MB/s and 20", maxRate, maxFiles)
+	}
+	if got := describeThrottle(throttle, opts); got != "50.0 MB/s and 20 files/s from 08:00 to 18:00" {
+		t.Errorf("describeThrottle() = %q", got)
+	}
+
+	if (&jobOptions{}).newThrottle() != nil {
+		t.Error("no throttle should be used without limits")
+	}
+}
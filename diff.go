@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// runDiff implements the diff command. It exits 0 when the destination is in
+// sync, 1 when there are differences and 2 on errors
+func runDiff(args []string, out io.Writer) int {
+	return compareDestination("diff", args, out)
+}
+
+// runVerify implements the verify command. Unlike diff it ignores files that
+// no archive provides, so it only fails when archive files are missing or
+// differ at the destination
+func runVerify(args []string, out io.Writer) int {
+	return compareDestination("verify", args, out)
+}
+
+func compareDestination(command string, args []string, out io.Writer) int {
+	opts := &jobOptions{}
+	flags := newCompareFlags(command, opts)
+	flags.SetOutput(out)
+	zipFiles, err := resolveJob(flags, opts, args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(out, "Error:", err)
+			fmt.Fprintf(out, "Usage: unzip-takeout %s [--json] [--hash-all] [--base-path=PATH] <destination_folder> <zip1> ... <zipN>\n", command)
+		}
+		return 2
+	}
+
+	extractorOpts := opts.extractorOptions()
+	extractorOpts.DryRun = true
+	extractor := takeout.New(extractorOpts)
+	defer saveHashCache(extractorOpts)
+
+	if opts.destination != "" {
+		if info, err := os.Stat(opts.destination); err != nil || !info.IsDir() {
+			fmt.Fprintf(out, "Error: destination %s is not a directory\n", opts.destination)
+			return 2
+		}
+	}
+
+	var keep []string
+	if opts.configPath != "" {
+		keep = append(keep, opts.configPath)
+	}
+	report, err := extractor.Diff(zipFiles, opts.hashAll, keep)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 2
+	}
+	if command == "verify" {
+		report.Extra = nil
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			*takeout.DiffReport
+			InSync bool `json:"in_sync"`
+		}{report, report.InSync()})
+		if err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 2
+		}
+	} else {
+		printDiff(out, report)
+	}
+
+	if !report.InSync() {
+		return 1
+	}
+	return 0
+}
+
+func printDiff(out io.Writer, r *takeout.DiffReport) {
+	for _, e := range r.Missing {
+		fmt.Fprintf(out, "- %s (missing, from %s)\n", e.Path, e.Archive)
+	}
+	for _, e := range r.Changed {
+		fmt.Fprintf(out, "M %s: %s\n", e.Path, e.Reason)
+	}
+	for _, e := range r.Metadata {
+		fmt.Fprintf(out, "T %s: %s\n", e.Path, e.Reason)
+	}
+	for _, e := range r.Extra {
+		fmt.Fprintf(out, "+ %s (not in any archive)\n", e.Path)
+	}
+	fmt.Fprintf(out, "\nIdentical: %d\nMissing: %d\nChanged: %d\nMetadata only: %d\nExtra: %d\n",
+		r.Identical, len(r.Missing), len(r.Changed), len(r.Metadata), len(r.Extra))
+	if r.InSync() {
+		fmt.Fprintln(out, "✅ Destination is in sync with the archives.")
+	} else {
+		fmt.Fprintln(out, "❌ Destination differs from the archives.")
+	}
+}
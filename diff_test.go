@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+func TestRunDiffExitCode(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+
+	var out bytes.Buffer
+	if code := runDiff([]string{"--json", destDir, zipPath}, &out); code != 1 {
+		t.Errorf("diff before extraction exit code = %d, want 1", code)
+	}
+	var report struct {
+		InSync  bool                `json:"in_sync"`
+		Missing []takeout.DiffEntry `json:"missing"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, out.String())
+	}
+	if report.InSync || len(report.Missing) != 1 {
+		t.Errorf("Unexpected JSON report: %s", out.String())
+	}
+
+	if _, err := takeout.New(takeout.Options{Destination: destDir, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := runDiff([]string{destDir, zipPath}, &out); code != 0 {
+		t.Errorf("diff after extraction exit code = %d, want 0\n%s", code, out.String())
+	}
+	if code := runDiff([]string{filepath.Join(destDir, "missing"), zipPath}, &out); code != 2 {
+		t.Errorf("diff with missing destination exit code = %d, want 2", code)
+	}
+}
+
+func TestRunVerifyIgnoresExtraFiles(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+
+	if _, err := takeout.New(takeout.Options{Destination: destDir, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(destDir, "notes.txt"), []byte("mine"), 0644)
+
+	var out bytes.Buffer
+	if code := runVerify([]string{destDir, zipPath}, &out); code != 0 {
+		t.Errorf("verify exit code = %d, want 0\n%s", code, out.String())
+	}
+	if code := runDiff([]string{destDir, zipPath}, &out); code != 1 {
+		t.Errorf("diff exit code = %d, want 1 for the extra file", code)
+	}
+}
+
+func TestConfigFileIsNotExtra(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+	configPath := filepath.Join(destDir, configFileName)
+	if err := os.WriteFile(configPath, []byte("workers: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runExtract([]string{"--auto", "--prune", "--prune-max-percent=0", destDir, zipPath}); code != 0 {
+		t.Fatalf("extract exit code = %d, want 0", code)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		t.Errorf("config file was pruned: %v", err)
+	}
+	var out bytes.Buffer
+	if code := runDiff([]string{destDir, zipPath}, &out); code != 0 {
+		t.Errorf("diff exit code = %d, want 0\n%s", code, out.String())
+	}
+}
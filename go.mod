@@ -2,11 +2,15 @@ module github.com/viclarsson/unzip-takeout
 
 go 1.22.4
 
-require github.com/schollz/progressbar/v3 v3.18.0
+require (
+	github.com/schollz/progressbar/v3 v3.18.0
+	golang.org/x/sys v0.29.0
+	golang.org/x/term v0.28.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sys v0.29.0 // indirect
-	golang.org/x/term v0.28.0 // indirect
 )
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// runInspect implements the ls, tree and stat commands and returns the
+// process exit code
+func runInspect(command string, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(out)
+	jsonOutput := fs.Bool("json", false, "Print output as JSON")
+	depth := fs.Int("depth", 0, "Maximum depth to print for tree (0 for unlimited)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		fmt.Fprintf(out, "Usage: unzip-takeout %s [--json] <zip> [path]\n", command)
+		return 2
+	}
+	zipPath, prefix := positional[0], ""
+	if len(positional) == 2 {
+		prefix = strings.Trim(positional[1], "/")
+	}
+
+	var result any
+	switch command {
+	case "stat":
+		if prefix != "" {
+			entry, err := takeout.StatEntry(zipPath, prefix)
+			if err != nil {
+				fmt.Fprintln(out, "Error:", err)
+				return 1
+			}
+			result = entry
+			break
+		}
+		stat, err := takeout.StatArchive(zipPath)
+		if err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+		result = stat
+	default:
+		tree, err := takeout.ListTree(zipPath, prefix)
+		if err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+		if prefix != "" && len(tree.Children) == 0 {
+			fmt.Fprintf(out, "Error: no entries under %s\n", prefix)
+			return 1
+		}
+		if command == "ls" {
+			result = tree.Entries()
+		} else {
+			result = tree
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+
+	switch v := result.(type) {
+	case []takeout.EntryInfo:
+		printEntries(out, v)
+	case *takeout.TreeNode:
+		printTree(out, v, "", *depth, 0)
+	case *takeout.ArchiveStat:
+		printStat(out, v)
+	case *takeout.EntryInfo:
+		printEntries(out, []takeout.EntryInfo{*v})
+	}
+	return 0
+}
+
+func printEntries(out io.Writer, entries []takeout.EntryInfo) {
+	for _, e := range entries {
+		name := e.Name[strings.LastIndex(e.Name, "/")+1:]
+		if e.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(out, "%10s  %10s  %5.1f%%  %s  %s\n",
+			formatSize(e.Size), formatSize(e.CompressedSize), e.Ratio*100,
+			e.Modified.Format("2006-01-02 15:04"), name)
+	}
+}
+
+func printTree(out io.Writer, n *takeout.TreeNode, indent string, maxDepth, depth int) {
+	name := n.Name[strings.LastIndex(n.Name, "/")+1:]
+	if depth == 0 && name == "" {
+		name = "."
+	}
+	if n.IsDir {
+		fmt.Fprintf(out, "%s%s/  (%d files, %s)\n", indent, name, n.Files, formatSize(n.Size))
+	} else {
+		fmt.Fprintf(out, "%s%s  (%s)\n", indent, name, formatSize(n.Size))
+	}
+	if maxDepth > 0 && depth >= maxDepth {
+		return
+	}
+	for _, c := range n.Children {
+		printTree(out, c, indent+"  ", maxDepth, depth+1)
+	}
+}
+
+func printStat(out io.Writer, s *takeout.ArchiveStat) {
+	fmt.Fprintf(out, "Archive: %s\nEntries: %d\nFiles: %d\nSize: %s\nCompressed: %s (%.1f%% saved)\n",
+		s.Path, s.Entries, s.Files, formatSize(s.Size), formatSize(s.CompressedSize), s.Ratio*100)
+	if s.Files > 0 {
+		fmt.Fprintf(out, "Modified: %s to %s\n", s.Oldest.Format("2006-01-02"), s.Newest.Format("2006-01-02"))
+	}
+	fmt.Fprintln(out, "\nProducts:")
+	for _, p := range s.Products {
+		fmt.Fprintf(out, "%10s  %8d files  %s\n", formatSize(p.Size), p.Files, p.Name)
+	}
+}
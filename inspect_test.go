@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// createInspectZip creates an archive of the named files, each holding its
+// own name. The inspect output itself is covered by the takeout tests
+func createInspectZip(t *testing.T, names ...string) string {
+	t.Helper()
+	files := make([]testFile, len(names))
+	for i, name := range names {
+		files[i] = testFile{name: name, content: name}
+	}
+	return createTestZip(t, files)
+}
+
+func TestRunInspectTree(t *testing.T) {
+	zipPath := createInspectZip(t, "Takeout/Drive/a.txt", "Takeout/Drive/Docs/b.txt", "Takeout/Mail/c.mbox")
+	defer os.Remove(zipPath)
+
+	var out bytes.Buffer
+	if code := runInspect("tree", []string{"--json", zipPath, "Takeout/Drive"}, &out); code != 0 {
+		t.Fatalf("tree exit code = %d, output: %s", code, out.String())
+	}
+	var tree takeout.TreeNode
+	if err := json.Unmarshal(out.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.Files != 2 || tree.Size != int64(len("Takeout/Drive/a.txt")+len("Takeout/Drive/Docs/b.txt")) {
+		t.Errorf("Tree totals = %d files, %d bytes; want 2 files under Takeout/Drive", tree.Files, tree.Size)
+	}
+	if len(tree.Children) != 2 || !tree.Children[0].IsDir || tree.Children[0].Name != "Takeout/Drive/Docs" {
+		t.Errorf("Expected Docs directory first, got %+v", tree.Children)
+	}
+}
+
+func TestRunInspectLs(t *testing.T) {
+	zipPath := createInspectZip(t, "Takeout/Drive/a.txt", "Takeout/Google Photos/c.jpg")
+	defer os.Remove(zipPath)
+
+	var out bytes.Buffer
+	if code := runInspect("ls", []string{zipPath, "Takeout"}, &out); code != 0 {
+		t.Fatalf("ls exit code = %d, output: %s", code, out.String())
+	}
+	for _, want := range []string{"Drive/", "Google Photos/"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("ls output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "a.txt") {
+		t.Errorf("ls should not recurse:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := runInspect("ls", []string{zipPath, "Missing"}, &out); code != 1 {
+		t.Errorf("ls of a missing path exit code = %d, want 1", code)
+	}
+
+	// Flags may follow the arguments
+	out.Reset()
+	if code := runInspect("ls", []string{zipPath, "Takeout", "--json"}, &out); code != 0 {
+		t.Fatalf("ls with a trailing flag exit code = %d, output: %s", code, out.String())
+	}
+	var entries []takeout.EntryInfo
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil || len(entries) != 2 {
+		t.Errorf("ls --json after the arguments = %v, %v:\n%s", entries, err, out.String())
+	}
+}
+
+func TestRunInspectStatEntry(t *testing.T) {
+	zipPath := createInspectZip(t, "Takeout/Drive/a.txt")
+	defer os.Remove(zipPath)
+
+	var out bytes.Buffer
+	if code := runInspect("stat", []string{"--json", zipPath, "Takeout/Drive/a.txt"}, &out); code != 0 {
+		t.Fatalf("stat exit code = %d, output: %s", code, out.String())
+	}
+	var entry takeout.EntryInfo
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != int64(len("Takeout/Drive/a.txt")) || entry.Method != "deflate" || entry.CRC32 == "" {
+		t.Errorf("Unexpected entry info: %+v", entry)
+	}
+}
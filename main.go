@@ -1,475 +1,342 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
-	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
+	"github.com/viclarsson/unzip-takeout/takeout"
 )
 
-var maxWorkers int
-var autoMode bool
-var dryRun bool
-var basePath string
-var logFile string
-
-const maxRetries = 3
-const assumedExtractionSpeed = 100 * 1024 * 1024 // 100MB/s extraction speed assumption
-const hashThreshold = 10 * 1024 * 1024           // Only hash files smaller than 10MB
-
-func init() {
-	flag.IntVar(&maxWorkers, "workers", 4, "Number of parallel extraction workers")
-	flag.BoolVar(&autoMode, "auto", false, "Skip confirmation and auto-start extraction")
-	flag.BoolVar(&dryRun, "dry-run", false, "Show extraction details without performing extraction")
-	flag.StringVar(&basePath, "base-path", "", "Base path within the ZIP file to start extraction from")
-	flag.StringVar(&logFile, "log", "", "Path to write extraction logs")
+// progressEvents prints extraction progress with a progress bar per archive
+type progressEvents struct {
+	takeout.NopEvents
+	opts *jobOptions
+	bar  *progressbar.ProgressBar
 }
 
-// ExtractionLog represents a single file extraction attempt
-type ExtractionLog struct {
-	Path      string    // Path within the zip
-	DestPath  string    // Destination path on disk
-	Size      int64     // File size
-	Status    string    // "Extracted", "Skipped", "Failed"
-	Reason    string    // Why it was skipped/failed, or empty for success
-	Timestamp time.Time // When the extraction was attempted
-	DryRun    bool      // Whether this was a dry run
-}
-
-type ZipExtractor struct {
-	workers    int
-	autoMode   bool
-	dryRun     bool
-	destFolder string
-	basePath   string
-	logs       []ExtractionLog
-	logsMutex  sync.Mutex // Add mutex for logs
-}
-
-func NewZipExtractor(workers int, autoMode bool, dryRun bool, destFolder string, basePath string) *ZipExtractor {
-	return &ZipExtractor{
-		workers:    workers,
-		autoMode:   autoMode,
-		dryRun:     dryRun,
-		destFolder: destFolder,
-		basePath:   filepath.Clean(basePath),
+// ArchiveStarted announces an archive and adds its files to the progress
+// bar, which spans every archive processed at once
+func (p *progressEvents) ArchiveStarted(archive string, files int) {
+	fmt.Printf("\nProcessing ZIP: %s\n", archive)
+	if p.opts.basePath != "" && p.opts.basePath != "." {
+		fmt.Printf("Starting from path: %s\n", p.opts.basePath)
 	}
-}
-
-type Duration struct {
-	Hours   int64
-	Minutes int64
-	Seconds int64
-}
-
-func formatDuration(seconds int64) Duration {
-	hours := seconds / 3600
-	minutes := (seconds % 3600) / 60
-	remainingSeconds := seconds % 60
-
-	return Duration{
-		Hours:   hours,
-		Minutes: minutes,
-		Seconds: remainingSeconds,
+	if p.opts.dryRun {
+		fmt.Printf("DRY RUN - Would extract %d files\n", files)
 	}
+	if p.bar != nil {
+		p.bar.ChangeMax(p.bar.GetMax() + files)
+		return
+	}
+	p.bar = progressbar.NewOptions(files,
+		progressbar.OptionSetDescription("Overall Progress"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetWidth(50),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionClearOnFinish(),
+	)
 }
 
-type ZipSummary struct {
-	Path             string
-	TotalFiles       int
-	AlreadyExtracted int
-	EstimatedTime    Duration
-}
-
-// FileInfo holds metadata about a file
-type FileInfo struct {
-	Size    int64
-	ModTime time.Time
-	Mode    os.FileMode
+func (p *progressEvents) FileDone(archive, name string) {
+	p.bar.Add(1)
 }
 
-// GetFileInfo returns size, modification time and mode of a file
-func GetFileInfo(path string) (*FileInfo, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, err
-	}
-	if info.IsDir() {
-		return nil, fmt.Errorf("path is a directory")
-	}
-	return &FileInfo{
-		Size:    info.Size(),
-		ModTime: info.ModTime(),
-		Mode:    info.Mode(),
-	}, nil
+func (p *progressEvents) ArchiveFinished(result *takeout.Result) {
+	fmt.Println("\nFinished processing ZIP:", result.Archive)
 }
 
-// IsFileEqual checks if a file at destPath matches the expected zip file entry
-func IsFileEqual(f *zip.File, destPath string) (bool, string) {
-	destInfo, err := GetFileInfo(destPath)
-	if err != nil {
-		return false, fmt.Sprintf("error accessing file: %v", err)
-	}
-
-	// Always check size first
-	if destInfo.Size != int64(f.UncompressedSize64) {
-		return false, fmt.Sprintf("size mismatch: zip=%d, existing=%d", f.UncompressedSize64, destInfo.Size)
-	}
-
-	// Always check modification time
-	timeDiff := destInfo.ModTime.Sub(f.Modified).Abs()
-	if timeDiff > 2*time.Second {
-		return false, fmt.Sprintf("time mismatch: zip=%v, existing=%v", f.Modified, destInfo.ModTime)
-	}
-
-	// For large files (>= hashThreshold), skip content comparison
-	if int64(f.UncompressedSize64) >= hashThreshold {
-		return true, ""
-	}
-
-	// For smaller files, also compare content hash
-	equal, err := compareFileHash(f, destPath)
+func writeLogsToFile(logs []takeout.ExtractionLog, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		return false, fmt.Sprintf("hash comparison error: %v", err)
-	}
-	if !equal {
-		return false, "content mismatch (different hash)"
+		return fmt.Errorf("failed to open log file: %w", err)
 	}
+	defer f.Close()
 
-	return true, ""
-}
-
-func compareFileHash(f *zip.File, destPath string) (bool, error) {
-	h1 := sha256.New()
-	h2 := sha256.New()
-
-	// Hash zip file content
-	rc, err := f.Open()
+	// Write header if file is empty
+	info, err := f.Stat()
 	if err != nil {
-		return false, err
+		return fmt.Errorf("failed to stat log file: %w", err)
 	}
-	defer rc.Close()
-	if _, err := io.Copy(h1, rc); err != nil {
-		return false, err
+	if info.Size() == 0 {
+		fmt.Fprintln(f, "Timestamp,Path,DestPath,Size,Status,Reason,DryRun")
 	}
 
-	// Hash existing file
-	file, err := os.Open(destPath)
-	if err != nil {
-		return false, err
-	}
-	defer file.Close()
-	if _, err := io.Copy(h2, file); err != nil {
-		return false, err
+	// Write logs in CSV format
+	for _, log := range logs {
+		_, err := fmt.Fprintf(f, "%s,%s,%s,%d,%s,%q,%v\n",
+			log.Timestamp.Format(time.RFC3339),
+			log.Path,
+			log.DestPath,
+			log.Size,
+			log.Status,
+			log.Reason,
+			log.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to write log: %w", err)
+		}
 	}
-
-	return bytes.Equal(h1.Sum(nil), h2.Sum(nil)), nil
-}
-
-func FileExists(path string) bool {
-	info, err := os.Stat(path)
-	return err == nil && !info.IsDir()
+	return nil
 }
 
-func (z *ZipExtractor) shouldIncludeFile(zipPath string) (string, bool) {
-	if z.basePath == "" || z.basePath == "." {
-		return zipPath, true
+// printWorkers reports the number of workers --workers=auto picked
+func printWorkers(extractor *takeout.Extractor, opts *jobOptions) {
+	if opts.workers != autoWorkers || opts.dryRun {
+		return
 	}
-
-	if !strings.HasPrefix(zipPath, z.basePath) {
-		return "", false
+	if workers, settled := extractor.Workers(); settled {
+		fmt.Printf("\n⚙️  Workers: settled on %d\n", workers)
+	} else {
+		fmt.Printf("\n⚙️  Workers: %d, still measuring when extraction finished\n", workers)
 	}
-
-	relPath := strings.TrimPrefix(zipPath, z.basePath)
-	relPath = strings.TrimPrefix(relPath, "/")
-	return relPath, true
 }
 
-func (z *ZipExtractor) EstimateTime(zipPath string) (*ZipSummary, error) {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("opening zip: %w", err)
+// printResult prints the extraction log of one archive
+func printResult(out io.Writer, result *takeout.Result, dryRun bool) {
+	// Print extraction summary with dry run indicator
+	if dryRun {
+		fmt.Fprintf(out, "\n🔍 DRY RUN - Extraction Log for %s:\n", result.Archive)
+	} else {
+		fmt.Fprintf(out, "\nExtraction Log for %s:\n", result.Archive)
 	}
-	defer r.Close()
-
-	var totalSize int64
-	var totalFiles, alreadyExtracted int
-
-	for _, f := range r.File {
-		relPath, include := z.shouldIncludeFile(f.Name)
-		if !include {
-			continue
+	fmt.Fprintln(out, "----------------------------------------")
+	for _, log := range result.Logs {
+		prefix := ""
+		if log.DryRun {
+			prefix = "[DRY RUN] "
 		}
 
-		totalFiles++
-		destPath := filepath.Join(z.destFolder, relPath)
-		if FileExists(destPath) {
-			alreadyExtracted++
-			continue
+		switch log.Status {
+		case "Extracted":
+			fmt.Fprintf(out, "%s✅ %s -> %s (%.2f MB)\n", prefix, log.Path, log.DestPath, float64(log.Size)/(1024*1024))
+		case "Skipped":
+			fmt.Fprintf(out, "%s⏭️  %s: %s\n", prefix, log.Path, log.Reason)
+		case "Failed":
+			fmt.Fprintf(out, "%s❌ %s: %s\n", prefix, log.Path, log.Reason)
+		case "Renamed":
+			fmt.Fprintf(out, "%s✏️  %s\n", prefix, log.Reason)
+		case "Decoded":
+			fmt.Fprintf(out, "%s🔤 %s: %s\n", prefix, log.Path, log.Reason)
+		case "Would Extract":
+			fmt.Fprintf(out, "%s🔍 %s -> %s (%.2f MB)\n", prefix, log.Path, log.DestPath, float64(log.Size)/(1024*1024))
+		case "Stopped":
+			fmt.Fprintf(out, "%s🛑 %s: %s\n", prefix, log.Path, log.Reason)
 		}
-		totalSize += int64(f.UncompressedSize64)
 	}
-
-	estimatedSeconds := totalSize / assumedExtractionSpeed
-	return &ZipSummary{zipPath, totalFiles, alreadyExtracted, formatDuration(estimatedSeconds)}, nil
-}
-
-func (z *ZipExtractor) Unzip(zipPath string) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to open zip: %w", err)
+	fmt.Fprintln(out, "----------------------------------------")
+	if result.Stopped > 0 {
+		fmt.Fprintf(out, "🛑 %d of %d files not written, the destination ran low on space\n", result.Stopped, result.Files)
 	}
-	defer r.Close()
+}
 
-	fmt.Printf("\nProcessing ZIP: %s\n", zipPath)
-	if z.basePath != "" && z.basePath != "." {
-		fmt.Printf("Starting from path: %s\n", z.basePath)
+// printSpaceChecks prints the free space preflight and reports whether any
+// filesystem is short
+func printSpaceChecks(out io.Writer, checks []takeout.SpaceCheck) bool {
+	var short bool
+	for _, check := range checks {
+		mark := "✅"
+		if check.Short() {
+			mark, short = "⚠️ ", true
+		}
+		fmt.Fprintf(out, "\n%s Free space for %s: %s available, %s needed, %s reserved\n",
+			mark, strings.Join(check.Destinations, ", "), formatSize(int64(check.Available)),
+			formatSize(max(check.Needed, 0)), formatSize(int64(check.Reserve)))
 	}
+	return short
+}
 
-	if z.dryRun {
-		fmt.Printf("DRY RUN - Would extract %d files\n", len(r.File))
-		for _, f := range r.File {
-			relPath, include := z.shouldIncludeFile(f.Name)
-			if !include {
-				continue
-			}
-			destPath := filepath.Join(z.destFolder, relPath)
-			if f.FileInfo().IsDir() {
-				continue
-			}
-			z.ExtractFile(f, destPath)
+// pruneDestination removes files no archive provides and prints what was pruned
+func pruneDestination(extractor *takeout.Extractor, zipFiles []string, opts *jobOptions) {
+	fmt.Println("\nPruning files not present in any archive...")
+	logsBefore := len(extractor.GetLogs())
+	var keep []string
+	for _, path := range []string{opts.logFile, opts.configPath} {
+		if path != "" {
+			keep = append(keep, path)
 		}
-		return nil
 	}
 
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, z.workers)
-
-	var extractionErrors []error
-	var errMutex sync.Mutex
-
-	totalFiles := len(r.File)
-	globalBar := progressbar.NewOptions(totalFiles,
-		progressbar.OptionSetDescription("Overall Progress"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionThrottle(65*time.Millisecond),
-		progressbar.OptionClearOnFinish(),
-	)
+	result, err := extractor.Prune(zipFiles, takeout.PruneOptions{
+		Delete:     opts.pruneDelete,
+		MaxPercent: opts.pruneMaxPercent,
+		Keep:       keep,
+	})
 
-	for _, f := range r.File {
-		relPath, include := z.shouldIncludeFile(f.Name)
-		if !include {
-			continue
+	logs := extractor.GetLogs()[logsBefore:]
+	for _, log := range logs {
+		switch log.Status {
+		case "Pruned":
+			fmt.Printf("🗑️  %s: %s\n", log.Path, log.Reason)
+		case "Would Prune":
+			fmt.Printf("[DRY RUN] 🗑️  %s\n", log.Path)
+		case "Failed":
+			fmt.Printf("❌ %s: %s\n", log.Path, log.Reason)
 		}
-
-		destPath := filepath.Join(z.destFolder, relPath)
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(destPath, os.ModePerm)
-			continue
+	}
+	if opts.logFile != "" {
+		if err := writeLogsToFile(logs, opts.logFile); err != nil {
+			fmt.Printf("Warning: Failed to write logs to file: %v\n", err)
 		}
-
-		wg.Add(1)
-		sem <- struct{}{}
-
-		go func(f *zip.File, destPath string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			if err := z.ExtractFile(f, destPath); err != nil {
-				errMutex.Lock()
-				extractionErrors = append(extractionErrors, fmt.Errorf("error extracting %s: %w", destPath, err))
-				errMutex.Unlock()
-			}
-			globalBar.Add(1)
-		}(f, destPath)
 	}
 
-	wg.Wait()
-	fmt.Println("\nFinished processing ZIP:", zipPath)
-
-	if len(extractionErrors) > 0 {
-		return fmt.Errorf("failed to extract some files: %v", extractionErrors[0])
+	if err != nil {
+		fmt.Println("Error pruning destination:", err)
+		return
+	}
+	fmt.Printf("Pruned %d of %d files (%.2f MB)\n", result.Pruned, result.TotalFiles, float64(result.PrunedSize)/(1024*1024))
+	if result.TrashPath != "" && result.Pruned > 0 && !opts.dryRun {
+		fmt.Println("Pruned files were moved to", result.TrashPath)
 	}
-	return nil
-}
-
-func (z *ZipExtractor) logExtraction(path, destPath string, size int64, status, reason string) {
-	z.logsMutex.Lock()
-	defer z.logsMutex.Unlock()
-	z.logs = append(z.logs, ExtractionLog{
-		Path:      path,
-		DestPath:  destPath,
-		Size:      size,
-		Status:    status,
-		Reason:    reason,
-		Timestamp: time.Now(),
-		DryRun:    z.dryRun,
-	})
 }
 
-func (z *ZipExtractor) GetLogs() []ExtractionLog {
-	z.logsMutex.Lock()
-	defer z.logsMutex.Unlock()
-	// Return a copy to prevent external modifications
-	logsCopy := make([]ExtractionLog, len(z.logs))
-	copy(logsCopy, z.logs)
-	return logsCopy
+// printUsage prints the commands and the flags of the extract command
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: unzip-takeout [extract] [flags] <destination_folder> <zip1> <zip2> ... <zipN>")
+	fmt.Fprintln(out, "       unzip-takeout plan [--out=PLAN] [flags] <destination_folder> <zip1> ... <zipN>")
+	fmt.Fprintln(out, "       unzip-takeout apply [--workers=N] [--log=PATH] [--min-free=SIZE] [--ignore-space] <plan>")
+	fmt.Fprintln(out, "       unzip-takeout verify|diff [--json] [flags] <destination_folder> <zip1> ... <zipN>")
+	fmt.Fprintln(out, "       unzip-takeout ls|tree|stat [--json] <zip> [path]")
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  extract                     Extract the archives into the destination (default)")
+	fmt.Fprintln(out, "  plan                        Show what extract would do, or save it as a plan file with --out")
+	fmt.Fprintln(out, "  apply                       Execute a saved plan if nothing changed since it was made")
+	fmt.Fprintln(out, "  verify                      Check that every archive file is present and unchanged")
+	fmt.Fprintln(out, "  diff                        Compare the destination with the archives, including extra files")
+	fmt.Fprintln(out, "  ls, tree, stat              Inspect the contents of an archive")
+	fmt.Fprintln(out, "\nFlags:")
+	fmt.Fprintln(out, "  --config=\"PATH\"             YAML config file for the job (default: <destination>/"+configFileName+")")
+	fmt.Fprintln(out, "  --workers=N                 Number of parallel extraction workers, or auto to pick it by measuring throughput (default: 4)")
+	fmt.Fprintln(out, "  --auto                      Skip confirmation and auto-start extraction")
+	fmt.Fprintln(out, "  --dry-run                   Show extraction details without performing extraction")
+	fmt.Fprintln(out, "  --no-symlinks               Skip symlink entries instead of recreating them")
+	fmt.Fprintln(out, "  --perms=POLICY              Permissions of extracted files: preserve, normalize or umask (default: preserve)")
+	fmt.Fprintln(out, "  --file-mode=MODE            Mode of files with --perms=normalize (default: 0644)")
+	fmt.Fprintln(out, "  --dir-mode=MODE             Mode of new folders with --perms=normalize (default: 0755)")
+	fmt.Fprintln(out, "  --chown=USER[:GROUP]        Give extracted files to another user and group (needs root)")
+	fmt.Fprintln(out, "  --xattrs                    Write descriptions, stars and creation times from Takeout sidecars as extended attributes")
+	fmt.Fprintln(out, "  --min-free=SIZE             Free space to keep on the destination filesystem (default: 1GB)")
+	fmt.Fprintln(out, "  --max-rate=RATE             Write at most this much per second, such as 50MB/s")
+	fmt.Fprintln(out, "  --max-file-rate=N           Write at most N files per second")
+	fmt.Fprintln(out, "  --throttle-hours=RANGE      Only apply the rate limits within this daily time range, such as 08:00-18:00")
+	fmt.Fprintln(out, "  --ignore-space              Warn instead of refusing when the destination looks too small")
+	fmt.Fprintln(out, "  --max-replace=N             Approve without prompting unless more than N files would be replaced")
+	fmt.Fprintln(out, "  --max-write=SIZE            Approve without prompting unless more than SIZE (such as 20GB) would be written")
+	fmt.Fprintln(out, "  --base-path=\"PATH\"          Base path within the ZIP file to start extraction from")
+	fmt.Fprintln(out, "  --name-profile=NAME         Filename rules of the destination: posix, windows or exfat (default: posix)")
+	fmt.Fprintln(out, "  --normalize=FORM            Unicode form of destination names: none, nfc or nfd (default: none)")
+	fmt.Fprintln(out, "  --archive-timezone=ZONE     Timezone of DOS timestamps in the archives: auto, UTC, Local, a name or an offset (default: auto)")
+	fmt.Fprintln(out, "  --compare=CHECKS            How existing files are compared: size, mtime, crc and sha256 joined by + (default: size+mtime+sha256)")
+	fmt.Fprintln(out, "  --hash-threshold=SIZE       Only hash files smaller than SIZE for crc and sha256, or 0 for every file (default: 10MB)")
+	fmt.Fprintln(out, "  --hash-cache=PATH           File to cache digests of destination files in, or off (default: auto, in the user cache folder)")
+	fmt.Fprintln(out, "  --charset=NAME              Charset of names stored without the UTF-8 flag: auto, utf-8, cp437, shift-jis or windows-1252 (default: auto)")
+	fmt.Fprintln(out, "  --map=\"SRC=DEST\"            Extract entries under SRC within the ZIP into DEST (repeatable)")
+	fmt.Fprintln(out, "  --log=\"PATH\"                Path to write extraction logs")
+	fmt.Fprintln(out, "  --split-mbox                Split .mbox files into per-message .eml files by Gmail label")
+	fmt.Fprintln(out, "  --split-items               Also split .vcf and .ics files into one file per contact or event")
+	fmt.Fprintln(out, "  --browse                    Interactively browse archive contents and pick folders to extract")
+	fmt.Fprintln(out, "  --include=\"PATH\"            Only extract entries under this path within the ZIP (repeatable)")
+	fmt.Fprintln(out, "  --exclude=\"PATH\"            Skip entries under this path within the ZIP (repeatable)")
+	fmt.Fprintln(out, "  --prune                     After extraction, remove destination files that no archive provides")
+	fmt.Fprintln(out, "  --prune-delete              Delete pruned files instead of moving them to a dated trash folder")
+	fmt.Fprintln(out, "  --prune-max-percent=N       Abort pruning if more than N% of files would be removed (default: 10)")
+	fmt.Fprintln(out, "\nEvery flag can also be set with an UNZIP_TAKEOUT_<FLAG> environment variable, such as")
+	fmt.Fprintln(out, "UNZIP_TAKEOUT_WORKERS=8, or as a key of the config file. Command-line flags take precedence.")
 }
 
-func (z *ZipExtractor) ExtractFile(f *zip.File, destPath string) error {
-	if z.dryRun {
-		equal, reason := IsFileEqual(f, destPath)
-		if equal {
-			z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Skipped", "File already exists and matches")
-			return nil
-		}
-		var extractReason string
-		if FileExists(destPath) {
-			extractReason = fmt.Sprintf("File exists but %s", reason)
-		} else {
-			extractReason = "File does not exist"
+func main() {
+	args := os.Args[1:]
+	command := "extract"
+	if len(args) > 0 {
+		switch args[0] {
+		case "extract", "plan", "apply", "verify", "diff", "ls", "tree", "stat":
+			command, args = args[0], args[1:]
+		case "help", "-h", "--help":
+			printUsage(os.Stdout)
+			return
 		}
-		z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Would Extract", extractReason)
-		return nil
-	}
-
-	equal, reason := IsFileEqual(f, destPath)
-	if equal {
-		z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Skipped", "File already exists and matches")
-		return nil
-	}
-	if FileExists(destPath) {
-		z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Replacing", reason)
 	}
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := ExtractAndVerify(f, destPath)
-		if err == nil {
-			z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Extracted", "")
-			return nil
-		}
-		if attempt < maxRetries {
-			z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Retry",
-				fmt.Sprintf("Attempt %d/%d failed: %v", attempt, maxRetries, err))
-		} else {
-			z.logExtraction(f.Name, destPath, int64(f.UncompressedSize64), "Failed",
-				fmt.Sprintf("All %d attempts failed: %v", maxRetries, err))
-		}
+	switch command {
+	case "plan":
+		os.Exit(runPlan(args, os.Stdout))
+	case "apply":
+		os.Exit(runApply(args))
+	case "verify":
+		os.Exit(runVerify(args, os.Stdout))
+	case "diff":
+		os.Exit(runDiff(args, os.Stdout))
+	case "ls", "tree", "stat":
+		os.Exit(runInspect(command, args, os.Stdout))
+	default:
+		os.Exit(runExtract(args))
 	}
-	return fmt.Errorf("failed after %d attempts: %s", maxRetries, destPath)
 }
 
-func ExtractAndVerify(f *zip.File, destPath string) error {
-	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
-		return err
+// runExtract implements the extract command and returns the process exit code
+func runExtract(args []string) int {
+	opts := &jobOptions{}
+	flags := newExtractFlags("extract", opts)
+	flags.Usage = func() { printUsage(os.Stdout) }
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return 1
 	}
-
-	srcFile, err := f.Open()
+	zipFiles, err := resolveJob(flags, opts, args)
 	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
-	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-	if err != nil {
-		return err
+		if err != flag.ErrHelp {
+			fmt.Println("Error:", err)
+			fmt.Println()
+			printUsage(os.Stdout)
+		}
+		return 1
 	}
-	defer destFile.Close()
 
-	_, err = io.Copy(destFile, srcFile)
-	if err != nil {
-		return err
+	// Under cron or CI a closed stdin makes every prompt read as "no"
+	interactive := stdinIsTerminal()
+	if !interactive && (opts.browse || !opts.autoMode && !opts.approval.active()) {
+		fmt.Println("Error: stdin is not a terminal, so prompts can't be answered.")
+		fmt.Println("Use --auto, or --max-replace and --max-write to approve within limits.")
+		return 1
 	}
 
-	// Close the file before setting timestamps
-	destFile.Close()
-
-	// Preserve timestamps from the zip file
-	modTime := f.Modified
-	if err := os.Chtimes(destPath, modTime, modTime); err != nil {
-		return fmt.Errorf("failed to set file times: %w", err)
+	if !opts.dryRun && opts.destination != "" {
+		if err := os.MkdirAll(opts.destination, os.ModePerm); err != nil {
+			fmt.Println("Error creating destination folder:", err)
+			return 1
+		}
 	}
 
-	return nil
-}
-
-func writeLogsToFile(logs []ExtractionLog, path string) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+	if opts.dryRun {
+		fmt.Println("DRY RUN!")
 	}
-	defer f.Close()
 
-	// Write header if file is empty
-	info, err := f.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat log file: %w", err)
-	}
-	if info.Size() == 0 {
-		fmt.Fprintln(f, "Timestamp,Path,DestPath,Size,Status,Reason,DryRun")
-	}
+	extractorOpts := opts.extractorOptions()
+	extractorOpts.Events = &progressEvents{opts: opts}
+	extractor := takeout.New(extractorOpts)
+	defer saveHashCache(extractorOpts)
 
-	// Write logs in CSV format
-	for _, log := range logs {
-		_, err := fmt.Fprintf(f, "%s,%s,%s,%d,%s,%q,%v\n",
-			log.Timestamp.Format(time.RFC3339),
-			log.Path,
-			log.DestPath,
-			log.Size,
-			log.Status,
-			log.Reason,
-			log.DryRun)
+	if opts.browse {
+		selection, err := BrowseArchives(extractor, zipFiles, os.Stdin, os.Stdout)
 		if err != nil {
-			return fmt.Errorf("failed to write log: %w", err)
+			fmt.Println("Error browsing archives:", err)
+			return 1
 		}
-	}
-	return nil
-}
-
-func main() {
-	flag.Parse()
-	args := flag.Args()
-
-	if len(args) < 2 {
-		fmt.Println("Usage: unzip-takeout [flags] <destination_folder> <zip1> <zip2> ... <zipN>")
-		fmt.Println("\nFlags must be specified before the destination folder and zip files.")
-		fmt.Println("\nFlags:")
-		fmt.Println("  --workers=N                 Number of parallel extraction workers (default: 4)")
-		fmt.Println("  --auto                      Skip confirmation and auto-start extraction")
-		fmt.Println("  --dry-run                   Show extraction details without performing extraction")
-		fmt.Println("  --base-path=\"PATH\"          Base path within the ZIP file to start extraction from")
-		fmt.Println("  --log=\"PATH\"                Path to write extraction logs")
-		os.Exit(1)
-	}
-
-	destFolder := args[0]
-	zipFiles := args[1:]
-
-	if !dryRun {
-		if err := os.MkdirAll(destFolder, os.ModePerm); err != nil {
-			fmt.Println("Error creating destination folder:", err)
-			os.Exit(1)
+		if selection == nil {
+			fmt.Println("🚫 Extraction canceled.")
+			return 0
 		}
+		extractor.SetFilters(selection.Includes, selection.Excludes)
 	}
 
-	if dryRun {
-		fmt.Println("DRY RUN!")
-	}
-
-	extractor := NewZipExtractor(maxWorkers, autoMode, dryRun, destFolder, basePath)
-
 	var confirmedZips []string
 	var totalEstimatedTime int64
 	var totalFilesToExtract int
+	var refused int
 
 	for _, zipFile := range zipFiles {
 		summary, err := extractor.EstimateTime(zipFile)
@@ -479,95 +346,164 @@ func main() {
 		}
 
 		filesToExtract := summary.TotalFiles - summary.AlreadyExtracted
-		fmt.Printf("\nZIP: %s\nTotal Files: %d\nAlready Extracted: %d\nFiles to Extract: %d\nEstimated Time: ~%dh %dm %ds\n",
-			zipFile, summary.TotalFiles, summary.AlreadyExtracted, filesToExtract,
-			summary.EstimatedTime.Hours, summary.EstimatedTime.Minutes, summary.EstimatedTime.Seconds)
+		printSummary(os.Stdout, summary)
 
-		if filesToExtract == 0 {
+		if filesToExtract == 0 && summary.Outdated == 0 {
 			fmt.Println("✅ Everything already extracted. Skipping...")
 			continue
 		}
 
-		if !autoMode {
-			var choice string
-			fmt.Print("Confirm extraction for this ZIP? (y/N): ")
-			fmt.Scanln(&choice)
-			if choice != "y" {
-				fmt.Println("Skipping...")
-				continue
-			}
+		decision := approveArchive(opts, interactive, zipFile, summary)
+		if decision.Status == "Refused" {
+			refused++
+		}
+		if decision.Status != "Approved" {
+			continue
 		}
 
 		confirmedZips = append(confirmedZips, zipFile)
-		totalEstimatedTime += int64(summary.EstimatedTime.Hours*3600 + summary.EstimatedTime.Minutes*60 + summary.EstimatedTime.Seconds)
+		totalEstimatedTime += summary.EstimatedTime.TotalSeconds()
 		totalFilesToExtract += filesToExtract
 	}
 
 	if len(confirmedZips) == 0 {
-		fmt.Println("\n✅ No extractions needed. Exiting.")
-		return
+		if opts.prune {
+			pruneDestination(extractor, zipFiles, opts)
+		} else {
+			fmt.Println("\n✅ No extractions needed. Exiting.")
+		}
+		if refused > 0 {
+			return 1
+		}
+		return 0
 	}
 
 	fmt.Printf("\nFinal Extraction Summary:\nConfirmed ZIPs: %d\nTotal Files to Extract: %d\nTotal Estimated Time: ~%dh %dm %ds",
 		len(confirmedZips), totalFilesToExtract,
-		formatDuration(totalEstimatedTime).Hours,
-		formatDuration(totalEstimatedTime).Minutes,
-		formatDuration(totalEstimatedTime).Seconds)
+		takeout.FormatDuration(totalEstimatedTime).Hours,
+		takeout.FormatDuration(totalEstimatedTime).Minutes,
+		takeout.FormatDuration(totalEstimatedTime).Seconds)
 
-	if !autoMode {
+	if !opts.dryRun {
+		checks, err := extractor.CheckSpace(confirmedZips)
+		if err != nil {
+			fmt.Println("\nError checking free space:", err)
+			return 1
+		}
+		if printSpaceChecks(os.Stdout, checks) && !opts.ignoreSpace {
+			fmt.Println("🚫 Not enough free space. Free up space, lower --min-free or pass --ignore-space.")
+			return 1
+		}
+	}
+
+	if !opts.autoMode && !opts.approval.active() {
 		var finalChoice string
 		fmt.Print("\nProceed with extraction? (y/N): ")
 		fmt.Scanln(&finalChoice)
 		if finalChoice != "y" {
 			fmt.Println("🚫 Extraction canceled.")
-			return
+			return 0
 		}
 	}
 
-	for _, zipFile := range confirmedZips {
-		err := extractor.Unzip(zipFile)
-		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", zipFile, err)
+	startThrottle(extractorOpts.Throttle, opts)
+	results, err := extractor.UnzipAll(confirmedZips)
+	if results == nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	var outOfSpace bool
+	for _, result := range results {
+		err := result.Err()
+		if errors.Is(err, takeout.ErrInsufficientSpace) {
+			printResult(os.Stdout, result, opts.dryRun)
+			fmt.Printf("🛑 Stopped while processing %s: %v\n", result.Archive, err)
+			if opts.logFile != "" {
+				if err := writeLogsToFile(result.Logs, opts.logFile); err != nil {
+					fmt.Printf("Warning: Failed to write logs to file: %v\n", err)
+				}
+			}
+			outOfSpace = true
 			continue
 		}
-
-		// Print extraction summary with dry run indicator
-		if dryRun {
-			fmt.Printf("\n🔍 DRY RUN - Extraction Log for %s:\n", zipFile)
-		} else {
-			fmt.Printf("\nExtraction Log for %s:\n", zipFile)
+		if err != nil {
+			fmt.Printf("Error processing %s: %v\n", result.Archive, err)
+			continue
 		}
-		fmt.Println("----------------------------------------")
-		for _, log := range extractor.GetLogs() {
-			prefix := ""
-			if log.DryRun {
-				prefix = "[DRY RUN] "
-			}
 
-			switch log.Status {
-			case "Extracted":
-				fmt.Printf("%s✅ %s -> %s (%.2f MB)\n", prefix, log.Path, log.DestPath, float64(log.Size)/(1024*1024))
-			case "Skipped":
-				fmt.Printf("%s⏭️  %s: %s\n", prefix, log.Path, log.Reason)
-			case "Failed":
-				fmt.Printf("%s❌ %s: %s\n", prefix, log.Path, log.Reason)
-			case "Would Extract":
-				fmt.Printf("%s🔍 %s -> %s (%.2f MB)\n", prefix, log.Path, log.DestPath, float64(log.Size)/(1024*1024))
-			}
-		}
-		fmt.Println("----------------------------------------")
+		printResult(os.Stdout, result, opts.dryRun)
 
 		// Write logs to file if requested
-		if logFile != "" {
-			if err := writeLogsToFile(extractor.GetLogs(), logFile); err != nil {
+		if opts.logFile != "" {
+			if err := writeLogsToFile(result.Logs, opts.logFile); err != nil {
 				fmt.Printf("Warning: Failed to write logs to file: %v\n", err)
 			}
 		}
 	}
 
-	if dryRun {
+	printWorkers(extractor, opts)
+
+	if outOfSpace {
+		fmt.Println("Free up space and run again to continue where it stopped.")
+		return 1
+	}
+
+	if opts.prune {
+		pruneDestination(extractor, zipFiles, opts)
+	}
+
+	if opts.dryRun {
 		fmt.Println("\n🔍 DRY RUN completed - no files were modified.")
 	} else {
 		fmt.Println("\n✅ All confirmed ZIP files processed successfully.")
 	}
+	if refused > 0 {
+		fmt.Printf("🚫 %d ZIP files were refused by the approval limits.\n", refused)
+		return 1
+	}
+	return 0
+}
+
+// approveArchive decides whether to extract an archive and logs the decision.
+// Limits approve without prompting; when they are exceeded the user is asked
+// if there is a terminal and --auto isn't set, otherwise the archive is refused
+func approveArchive(opts *jobOptions, interactive bool, zipFile string, summary *takeout.ZipSummary) takeout.ExtractionLog {
+	var decision takeout.ExtractionLog
+	switch ok, reason := opts.approval.check(summary); {
+	case opts.approval.active() && ok:
+		decision = approvalLog(zipFile, "Approved", "automatically, "+reason)
+	case opts.approval.active() && (opts.autoMode || !interactive):
+		decision = approvalLog(zipFile, "Refused", reason)
+	case opts.autoMode:
+		decision = approvalLog(zipFile, "Approved", "automatically, --auto")
+	default:
+		if opts.approval.active() {
+			fmt.Println("⚠️ ", reason)
+		}
+		var choice string
+		fmt.Print("Confirm extraction for this ZIP? (y/N): ")
+		fmt.Scanln(&choice)
+		if choice == "y" {
+			decision = approvalLog(zipFile, "Approved", "by the user")
+		} else {
+			fmt.Println("Skipping...")
+			decision = approvalLog(zipFile, "Declined", "by the user")
+		}
+	}
+
+	switch decision.Status {
+	case "Approved":
+		opts.approval.approve(summary)
+		if !strings.HasSuffix(decision.Reason, "by the user") {
+			fmt.Println("✅ Approved", decision.Reason)
+		}
+	case "Refused":
+		fmt.Println("🚫 Refused:", decision.Reason)
+	}
+	if opts.logFile != "" {
+		if err := writeLogsToFile([]takeout.ExtractionLog{decision}, opts.logFile); err != nil {
+			fmt.Printf("Warning: Failed to write logs to file: %v\n", err)
+		}
+	}
+	return decision
 }
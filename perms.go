@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// fileMode is a flag value for octal permissions such as 0644. Zero means
+// unset
+type fileMode os.FileMode
+
+func (m *fileMode) String() string {
+	if *m == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04o", uint32(*m))
+}
+
+func (m *fileMode) Set(value string) error {
+	n, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || n == 0 || n > 0777 {
+		return fmt.Errorf("invalid mode %q, want octal permissions such as 0644", value)
+	}
+	*m = fileMode(n)
+	return nil
+}
+
+// ownerFlag is a flag value for USER[:GROUP], by name or numeric ID. Changing
+// the owner of files needs root
+type ownerFlag struct {
+	owner *takeout.Owner
+}
+
+func (o *ownerFlag) String() string {
+	if o.owner == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", o.owner.UID, o.owner.GID)
+}
+
+func (o *ownerFlag) Set(value string) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("changing the owner of extracted files needs root")
+	}
+	userName, groupName, _ := strings.Cut(value, ":")
+	owner := &takeout.Owner{UID: -1, GID: -1}
+	if userName != "" {
+		uid, err := lookupID(userName, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return err
+		}
+		owner.UID = uid
+	}
+	if groupName != "" {
+		gid, err := lookupID(groupName, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return err
+		}
+		owner.GID = gid
+	}
+	if owner.UID < 0 && owner.GID < 0 {
+		return fmt.Errorf("owner %q names no user or group", value)
+	}
+	o.owner = owner
+	return nil
+}
+
+// lookupID returns a numeric ID as is, or looks up the ID of a name
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// addPermFlags registers the flags that control the permissions, owner and
+// metadata of extracted files, shared by the commands that write files
+func addPermFlags(flags *flag.FlagSet, opts *jobOptions) {
+	flags.StringVar(&opts.perms, "perms", "preserve", "Permissions of extracted files: preserve, normalize or umask")
+	flags.Var(&opts.fileMode, "file-mode", "Mode of files with --perms=normalize (default 0644)")
+	flags.Var(&opts.dirMode, "dir-mode", "Mode of new folders with --perms=normalize (default 0755)")
+	flags.Var(&opts.owner, "chown", "Give extracted files to USER[:GROUP] (needs root)")
+	flags.BoolVar(&opts.xattrs, "xattrs", false, "Write descriptions, stars and creation times from Takeout sidecars as extended attributes")
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// runPlan implements the plan command: it estimates the work extract would
+// do for the same flags without prompting or touching the destination, and
+// with --out saves the action for every entry as a plan file for apply
+func runPlan(args []string, out io.Writer) int {
+	opts := &jobOptions{}
+	flags := newExtractFlags("plan", opts)
+	flags.SetOutput(out)
+	planFile := flags.String("out", "", "Write the plan to this file for a later apply")
+	zipFiles, err := resolveJob(flags, opts, args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(out, "Error:", err)
+			fmt.Fprintln(out, "Usage: unzip-takeout plan [--out=PLAN] [flags] <destination_folder> <zip1> ... <zipN>")
+		}
+		return 2
+	}
+
+	extractorOpts := opts.extractorOptions()
+	extractorOpts.DryRun = true
+	extractor := takeout.New(extractorOpts)
+	defer saveHashCache(extractorOpts)
+
+	if *planFile != "" {
+		return writePlan(out, extractor, zipFiles, *planFile)
+	}
+
+	var failed, pending int
+	var totalFiles int
+	var totalEstimatedTime int64
+	for _, zipFile := range zipFiles {
+		summary, err := extractor.EstimateTime(zipFile)
+		if err != nil {
+			fmt.Fprintln(out, "Error reading ZIP:", zipFile, err)
+			failed++
+			continue
+		}
+		printSummary(out, summary)
+		if files := summary.TotalFiles - summary.AlreadyExtracted; files > 0 || summary.Outdated > 0 {
+			pending++
+			totalFiles += files
+			totalEstimatedTime += summary.EstimatedTime.TotalSeconds()
+		}
+	}
+
+	checks, err := extractor.CheckSpace(zipFiles)
+	if err != nil {
+		fmt.Fprintln(out, "Error checking free space:", err)
+		failed++
+	}
+	printSpaceChecks(out, checks)
+
+	total := takeout.FormatDuration(totalEstimatedTime)
+	fmt.Fprintf(out, "\nPlan Summary:\nZIPs with Files to Extract: %d of %d\nTotal Files to Extract: %d\nTotal Estimated Time: ~%dh %dm %ds\n",
+		pending, len(zipFiles), totalFiles, total.Hours, total.Minutes, total.Seconds)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printSummary prints the estimate for one archive
+func printSummary(out io.Writer, summary *takeout.ZipSummary) {
+	fmt.Fprintf(out, "\nZIP: %s\nTotal Files: %d\nAlready Extracted: %d\nFiles to Extract: %d\nFiles to Replace: %d\nEstimated Time: ~%dh %dm %ds\n",
+		summary.Path, summary.TotalFiles, summary.AlreadyExtracted, summary.TotalFiles-summary.AlreadyExtracted, summary.Outdated,
+		summary.EstimatedTime.Hours, summary.EstimatedTime.Minutes, summary.EstimatedTime.Seconds)
+	if summary.Timezone != "" {
+		fmt.Fprintf(out, "DOS Timestamps: %s\n", summary.Timezone)
+	}
+}
+
+// writePlan saves the plan for the archives and prints its totals and conflicts
+func writePlan(out io.Writer, extractor *takeout.Extractor, zipFiles []string, path string) int {
+	plan, err := extractor.Plan(zipFiles)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	if err := takeout.WritePlan(path, plan); err != nil {
+		fmt.Fprintln(out, "Error writing plan:", err)
+		return 1
+	}
+
+	for _, e := range plan.Entries {
+		if e.Action == takeout.ActionConflict {
+			fmt.Fprintf(out, "⚠️  %s -> %s: %s\n", e.Entry, e.Destination, e.Reason)
+		}
+	}
+	fmt.Fprintf(out, "\nPlan written to %s\nExtract: %d\nReplace: %d\nSkip: %d\nConflict: %d\n",
+		path, plan.Count(takeout.ActionExtract), plan.Count(takeout.ActionReplace),
+		plan.Count(takeout.ActionSkip), plan.Count(takeout.ActionConflict))
+	return 0
+}
+
+// runApply implements the apply command: it executes a saved plan and
+// refuses when the archives or the destination changed since
+func runApply(args []string) int {
+	opts := &jobOptions{}
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	addWorkersFlag(flags, opts)
+	flags.StringVar(&opts.logFile, "log", "", "Path to write extraction logs")
+	opts.minFree = 1 << 30
+	flags.Var(&opts.minFree, "min-free", "Free space to keep on the destination filesystem, such as 5GB")
+	flags.BoolVar(&opts.ignoreSpace, "ignore-space", false, "Warn instead of refusing when the destination looks too small")
+	addPermFlags(flags, opts)
+	addHashCacheFlag(flags, opts)
+	addThrottleFlags(flags, opts)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Println("Usage: unzip-takeout apply [--workers=N] [--log=PATH] [--min-free=SIZE] [--ignore-space] [--perms=POLICY] <plan>")
+		return 2
+	}
+	perms, err := takeout.ParsePermPolicy(opts.perms)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 2
+	}
+
+	plan, err := takeout.ReadPlan(positional[0])
+	if err != nil {
+		fmt.Println("Error reading plan:", err)
+		return 1
+	}
+	timezone, err := takeout.ParseTimezone(plan.Timezone)
+	if err != nil {
+		fmt.Println("Error reading plan:", err)
+		return 1
+	}
+	comparator, err := plan.Comparator()
+	if err != nil {
+		fmt.Println("Error reading plan:", err)
+		return 1
+	}
+	if s, ok := comparator.(takeout.Strategy); ok {
+		s.Cache = opts.newHashCache()
+		comparator = s
+	}
+	if changes := plan.Changes(); len(changes) > 0 {
+		fmt.Println("❌ Refusing to apply, things changed since the plan was made:")
+		for _, change := range changes {
+			fmt.Println("  " + change)
+		}
+		return 1
+	}
+
+	extractor := takeout.New(takeout.Options{
+		Workers:     int(opts.workers),
+		AutoWorkers: opts.workers == autoWorkers,
+		MinFree:     uint64(max(opts.minFree, 0)),
+		SplitMbox:   plan.SplitMbox,
+		SplitItems:  plan.SplitItems,
+		Charset:     plan.Charset,
+		Timezone:    timezone,
+		Compare:     comparator,
+		NoSymlinks:  plan.NoSymlinks,
+		Perms:       perms,
+		FileMode:    os.FileMode(opts.fileMode),
+		DirMode:     os.FileMode(opts.dirMode),
+		Owner:       opts.owner.owner,
+		Metadata:    opts.xattrs,
+		Throttle:    opts.newThrottle(),
+		Events:      &progressEvents{opts: opts},
+	})
+	defer saveHashCache(extractor.Options())
+
+	checks, err := extractor.CheckPlanSpace(plan)
+	if err != nil {
+		fmt.Println("Error checking free space:", err)
+		return 1
+	}
+	if printSpaceChecks(os.Stdout, checks) && !opts.ignoreSpace {
+		fmt.Println("🚫 Not enough free space. Free up space, lower --min-free or pass --ignore-space.")
+		return 1
+	}
+
+	startThrottle(extractor.Options().Throttle, opts)
+	results, err := extractor.Apply(plan)
+	for _, result := range results {
+		printResult(os.Stdout, result, false)
+		if opts.logFile != "" {
+			if err := writeLogsToFile(result.Logs, opts.logFile); err != nil {
+				fmt.Printf("Warning: Failed to write logs to file: %v\n", err)
+			}
+		}
+	}
+	printWorkers(extractor, opts)
+	if errors.Is(err, takeout.ErrInsufficientSpace) {
+		fmt.Println("🛑 Stopped applying the plan:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Println("Error applying plan:", err)
+		return 1
+	}
+	fmt.Println("\n✅ Plan applied successfully.")
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPlanAndApply(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "Takeout/Drive/a.txt", content: "a"},
+		{name: "Takeout/Drive/b.txt", content: "b"},
+	})
+	defer os.Remove(zipPath)
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+
+	var out bytes.Buffer
+	if code := runPlan([]string{destDir, zipPath, "--base-path=Takeout", "--out", planPath}, &out); code != 0 {
+		t.Fatalf("plan exit code = %d\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "Extract: 2") {
+		t.Errorf("Plan output missing totals:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "Drive")); err == nil {
+		t.Fatal("plan must not write to the destination")
+	}
+
+	if code := runApply([]string{planPath}); code != 0 {
+		t.Fatalf("apply exit code = %d", code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destDir, "Drive", "a.txt")); string(data) != "a" {
+		t.Errorf("a.txt = %q after apply, want %q", data, "a")
+	}
+
+	// The destination now differs from what the plan recorded
+	if code := runApply([]string{planPath}); code != 1 {
+		t.Errorf("apply of a stale plan exit code = %d, want 1", code)
+	}
+}
+
+func TestRunApplyChecksSpace(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if code := runPlan([]string{destDir, zipPath, "--out", planPath}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("plan exit code = %d", code)
+	}
+
+	if code := runApply([]string{"--min-free=1000000TB", planPath}); code != 1 {
+		t.Errorf("apply without enough space exit code = %d, want 1", code)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "a.txt")); err == nil {
+		t.Error("apply must not write anything when short of space")
+	}
+}
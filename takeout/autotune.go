@@ -0,0 +1,198 @@
+package takeout
+
+import (
+	"sync"
+	"time"
+)
+
+// Bounds of the number of workers picked by AutoWorkers
+const (
+	autoStartWorkers = 2
+	autoMaxWorkers   = 16
+)
+
+// tuneWindow is the least time each number of workers is measured for
+const tuneWindow = 2 * time.Second
+
+// minGain is how much faster a number of workers must be to count as better,
+// so noise doesn't decide
+const minGain = 0.05
+
+// limiter bounds how many workers run at once, to a limit that can change
+// while they run
+type limiter struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newLimiter(limit int) *limiter {
+	l := &limiter{limit: limit}
+	l.cond = sync.NewCond(&l.mutex)
+	return l
+}
+
+// acquire waits until fewer workers than the limit are running
+func (l *limiter) acquire() {
+	l.mutex.Lock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+	l.mutex.Unlock()
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	l.active--
+	l.mutex.Unlock()
+	l.cond.Broadcast()
+}
+
+func (l *limiter) setLimit(limit int) {
+	l.mutex.Lock()
+	l.limit = limit
+	l.mutex.Unlock()
+	l.cond.Broadcast()
+}
+
+// levelSample is what was measured while running a number of workers
+type levelSample struct {
+	workers int
+	rate    float64       // Bytes written per second
+	latency time.Duration // Average time to write a file
+}
+
+// betterThan reports whether s is clearly faster than other, or as fast with
+// lower latency
+func (s levelSample) betterThan(other levelSample) bool {
+	switch {
+	case other.workers == 0:
+		return true
+	case s.rate > other.rate*(1+minGain):
+		return true
+	case s.rate >= other.rate*(1-minGain):
+		return s.latency < other.latency
+	}
+	return false
+}
+
+// tunePhase is the step the tuner is at
+type tunePhase int
+
+const (
+	tuneRamp    tunePhase = iota // Doubling the workers while it helps
+	tuneRefine                   // Measuring levels near the best
+	tuneSettled                  // Running at the best level found
+)
+
+// tuner picks the number of workers by measuring the write rate and latency
+// of each level in turn. It starts small, doubles the workers while that
+// makes extraction faster, tries the levels around the best, and settles on
+// it. It is safe for concurrent use
+type tuner struct {
+	mutex   sync.Mutex
+	limiter *limiter
+	window  time.Duration
+	phase   tunePhase
+	best    levelSample
+	probes  []int // Levels left to measure while refining
+
+	// The level being measured
+	workers int
+	started time.Time
+	bytes   int64
+	files   int
+	busy    time.Duration
+}
+
+func newTuner() *tuner {
+	return &tuner{
+		limiter: newLimiter(autoStartWorkers),
+		window:  tuneWindow,
+		workers: autoStartWorkers,
+	}
+}
+
+// Workers returns the number of workers in use, and whether it is settled
+func (t *tuner) Workers() (int, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.workers, t.phase == tuneSettled
+}
+
+// record adds a file written in took to the measurement of the current level,
+// and moves to the next level once it has been measured long enough
+func (t *tuner) record(bytes int64, took time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.phase == tuneSettled {
+		return
+	}
+	if t.started.IsZero() {
+		t.started = time.Now().Add(-took)
+	}
+	t.bytes += bytes
+	t.files++
+	t.busy += took
+
+	elapsed := time.Since(t.started)
+	if elapsed < t.window || t.files < 2*t.workers {
+		return
+	}
+	t.next(levelSample{
+		workers: t.workers,
+		rate:    float64(t.bytes) / elapsed.Seconds(),
+		latency: t.busy / time.Duration(t.files),
+	})
+}
+
+// restart discards what was measured of the current level, after a file was
+// slowed down by something other than the disk, such as a throttle
+func (t *tuner) restart() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.phase != tuneSettled {
+		t.started, t.bytes, t.files, t.busy = time.Time{}, 0, 0, 0
+	}
+}
+
+// next picks the level to measure after sample, or settles
+func (t *tuner) next(sample levelSample) {
+	improved := sample.betterThan(t.best)
+	if improved {
+		t.best = sample
+	}
+
+	if t.phase == tuneRamp {
+		if improved && sample.workers*2 <= autoMaxWorkers {
+			t.measure(sample.workers * 2)
+			return
+		}
+		// Doubling stopped helping, so try between the best and the last
+		// level, and below the start when that was the best
+		t.phase = tuneRefine
+		if mid := (t.best.workers + sample.workers) / 2; !improved && mid != t.best.workers {
+			t.probes = append(t.probes, mid)
+		}
+		if t.best.workers == autoStartWorkers {
+			t.probes = append(t.probes, 1)
+		}
+	}
+	if len(t.probes) > 0 {
+		workers := t.probes[0]
+		t.probes = t.probes[1:]
+		t.measure(workers)
+		return
+	}
+	t.phase = tuneSettled
+	t.measure(t.best.workers)
+}
+
+// measure starts measuring a number of workers
+func (t *tuner) measure(workers int) {
+	t.workers = workers
+	t.started, t.bytes, t.files, t.busy = time.Time{}, 0, 0, 0
+	t.limiter.setLimit(workers)
+}
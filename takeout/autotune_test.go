@@ -0,0 +1,83 @@
+package takeout
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// settle feeds the tuner samples from rate until it settles
+func settle(t *testing.T, rate func(workers int) float64) int {
+	t.Helper()
+	tn := newTuner()
+	for i := 0; i < 20; i++ {
+		workers, settled := tn.Workers()
+		if settled {
+			return workers
+		}
+		tn.next(levelSample{workers: workers, rate: rate(workers), latency: time.Duration(workers) * time.Millisecond})
+	}
+	t.Fatal("tuner did not settle")
+	return 0
+}
+
+func TestTunerSettles(t *testing.T) {
+	tests := []struct {
+		name string
+		rate func(workers int) float64
+		want int
+	}{
+		{"scales", func(w int) float64 { return float64(w) }, autoMaxWorkers},
+		{"peaks at 6", func(w int) float64 { return 100 - 5*float64((w-6)*(w-6)) }, 6},
+		{"spinning disk", func(w int) float64 { return 100 / float64(w) }, 1},
+		{"flat", func(w int) float64 { return 100 }, 1},
+	}
+	for _, tt := range tests {
+		if got := settle(t, tt.rate); got != tt.want {
+			t.Errorf("%s: settled on %d workers, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAutoWorkersExtract(t *testing.T) {
+	zipPath := createTestZip(t, []testFile{
+		{name: "a.txt", content: "a"}, {name: "b.txt", content: "b"},
+		{name: "c.txt", content: "c"}, {name: "d.txt", content: "d"},
+	})
+	defer os.Remove(zipPath)
+
+	z := New(Options{Destination: t.TempDir(), AutoWorkers: true})
+	z.tuner.window = 0
+	result, err := z.Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 4 {
+		t.Errorf("extracted %d files, want 4", result.Extracted)
+	}
+	if workers, _ := z.Workers(); workers < 1 || workers > autoMaxWorkers {
+		t.Errorf("Workers() = %d, want between 1 and %d", workers, autoMaxWorkers)
+	}
+}
+
+func TestAutoWorkersIgnoresThrottledFiles(t *testing.T) {
+	var files []testFile
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files = append(files, testFile{name: name + ".txt", content: name})
+	}
+	zipPath := createTestZip(t, files)
+	defer os.Remove(zipPath)
+
+	throttle := NewThrottle(0, 1)
+	throttle.sleep = func(time.Duration) {}
+	z := New(Options{Destination: t.TempDir(), AutoWorkers: true, Throttle: throttle})
+	z.tuner.window = 0
+	if _, err := z.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	// Files the throttle held back are not measured, so the first level
+	// never gets enough of them to move on
+	if workers, _ := z.Workers(); workers != autoStartWorkers || z.tuner.files != 0 {
+		t.Errorf("tuner at %d workers with %d files measured, want %d and none", workers, z.tuner.files, autoStartWorkers)
+	}
+}
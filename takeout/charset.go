@@ -0,0 +1,238 @@
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Charset selects how entry names stored without the UTF-8 flag are decoded
+type Charset string
+
+const (
+	// CharsetAuto guesses the charset of each archive from its names
+	CharsetAuto Charset = "auto"
+	// CharsetUTF8 keeps names as they are
+	CharsetUTF8 Charset = "utf-8"
+	// CharsetCP437 is the original IBM PC charset the zip format defaults to
+	CharsetCP437 Charset = "cp437"
+	// CharsetShiftJIS is used by archives created on Japanese Windows
+	CharsetShiftJIS Charset = "shift-jis"
+	// CharsetWindows1252 is used by archives created on western Windows
+	CharsetWindows1252 Charset = "windows-1252"
+)
+
+// ParseCharset returns the charset with the name, where empty means auto
+func ParseCharset(name string) (Charset, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "", "auto":
+		return CharsetAuto, nil
+	case "utf-8", "utf8":
+		return CharsetUTF8, nil
+	case "cp437", "ibm437":
+		return CharsetCP437, nil
+	case "shift-jis", "shiftjis", "sjis", "cp932":
+		return CharsetShiftJIS, nil
+	case "windows-1252", "cp1252":
+		return CharsetWindows1252, nil
+	}
+	return "", fmt.Errorf("unknown charset %q, want auto, utf-8, cp437, shift-jis or windows-1252", name)
+}
+
+func (c Charset) encoding() encoding.Encoding {
+	switch c {
+	case CharsetCP437:
+		return charmap.CodePage437
+	case CharsetShiftJIS:
+		return japanese.ShiftJIS
+	case CharsetWindows1252:
+		return charmap.Windows1252
+	}
+	return encoding.Nop
+}
+
+func (c Charset) decode(raw string) (string, bool) {
+	name, err := c.encoding().NewDecoder().String(raw)
+	if err != nil || strings.ContainsRune(name, utf8.RuneError) {
+		return name, false
+	}
+	return name, true
+}
+
+// NameDecoding records how an entry name stored without the UTF-8 flag was
+// decoded
+type NameDecoding struct {
+	Raw      string  // Name as stored in the archive
+	Charset  Charset // Charset it was decoded from, empty for a Unicode path field
+	Detected bool    // Whether the charset was guessed rather than configured
+}
+
+// Reason describes the decoding for the extraction log
+func (d NameDecoding) Reason() string {
+	switch {
+	case d.Charset == "":
+		return "Name stored without the UTF-8 flag, using its Unicode path field"
+	case d.Detected:
+		return fmt.Sprintf("Name stored without the UTF-8 flag, decoded as %s (detected)", d.Charset)
+	}
+	return fmt.Sprintf("Name stored without the UTF-8 flag, decoded as %s", d.Charset)
+}
+
+// DecodeNames rewrites the names of entries stored without the UTF-8 flag in
+// place, so filters and mappings see readable names, and returns how each
+// rewritten name was decoded. With CharsetAuto, names that are valid UTF-8 are
+// kept and one charset is guessed for the rest of the archive
+func DecodeNames(files []*zip.File, charset Charset) map[string]NameDecoding {
+	decoded := map[string]NameDecoding{}
+	var undecided []*zip.File
+	for _, f := range files {
+		if !f.NonUTF8 {
+			continue
+		}
+		if name, ok := unicodePathField(f); ok {
+			decoded[name] = NameDecoding{Raw: f.Name}
+			f.Name = name
+			continue
+		}
+		if charset == CharsetAuto && utf8.ValidString(f.Name) || charset == CharsetUTF8 {
+			continue
+		}
+		undecided = append(undecided, f)
+	}
+	if len(undecided) == 0 {
+		return decoded
+	}
+
+	detected := charset == CharsetAuto
+	if detected {
+		raw := make([]string, len(undecided))
+		for i, f := range undecided {
+			raw[i] = f.Name
+		}
+		charset = guessCharset(raw)
+	}
+	for _, f := range undecided {
+		name, _ := charset.decode(f.Name)
+		if name == f.Name {
+			continue
+		}
+		decoded[name] = NameDecoding{Raw: f.Name, Charset: charset, Detected: detected}
+		f.Name = name
+	}
+	return decoded
+}
+
+// unicodePathField returns the UTF-8 name from an Info-ZIP Unicode Path extra
+// field, if the entry has one that matches its stored name
+func unicodePathField(f *zip.File) (string, bool) {
+	extra := f.Extra
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+		if tag != 0x7075 || len(field) < 5 || field[0] != 1 {
+			continue
+		}
+		// The field only applies while its CRC matches the stored name
+		if binary.LittleEndian.Uint32(field[1:5]) != crc32.ChecksumIEEE([]byte(f.Name)) {
+			continue
+		}
+		if name := string(field[5:]); utf8.ValidString(name) && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// guessCharset picks the charset the raw names most plausibly use. Shift-JIS
+// wins when every name decodes cleanly and some contain kana. Otherwise the
+// single-byte charset turning more bytes into Latin letters wins, with CP437 as the
+// zip format default on ties
+func guessCharset(raw []string) Charset {
+	joined := strings.Join(raw, "/")
+	if name, ok := CharsetShiftJIS.decode(joined); ok && strings.IndexFunc(name, isKana) >= 0 {
+		return CharsetShiftJIS
+	}
+	if letterScore(CharsetWindows1252, joined) > letterScore(CharsetCP437, joined) {
+		return CharsetWindows1252
+	}
+	return CharsetCP437
+}
+
+func isKana(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana)
+}
+
+// letterScore counts the non-ASCII characters a charset decodes to Latin
+// letters, less those it decodes to symbols or box drawing. Greek letters,
+// which CP437 has where Windows-1252 has accented ones, count for neither
+func letterScore(c Charset, raw string) int {
+	name, _ := c.decode(raw)
+	score := 0
+	for _, r := range name {
+		switch {
+		case r < utf8.RuneSelf:
+		case unicode.Is(unicode.Latin, r):
+			score++
+		case !unicode.IsLetter(r):
+			score--
+		}
+	}
+	return score
+}
+
+// openArchive opens a zip file and decodes names stored without the UTF-8
+// flag using charset
+func openArchive(path string, charset Charset) (*zip.ReadCloser, map[string]NameDecoding, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, DecodeNames(r.File, charset), nil
+}
+
+// OpenArchive opens a zip file with its entry names decoded using the
+// extractor's charset, before they are filtered or mapped, and DOS timestamps
+// read in the archive's timezone
+func (z *Extractor) OpenArchive(path string) (*zip.ReadCloser, error) {
+	r, decoded, err := openArchive(path, z.charset)
+	if err != nil {
+		return nil, err
+	}
+	zone := fixDOSTimes(r.File, z.timezone)
+	z.namesMutex.Lock()
+	for name, decoding := range decoded {
+		z.decodings[name] = decoding
+	}
+	z.zones[path] = zone
+	z.namesMutex.Unlock()
+	return r, nil
+}
+
+// archiveTimezone describes the timezone the DOS timestamps of an opened
+// archive were read in
+func (z *Extractor) archiveTimezone(path string) string {
+	z.namesMutex.Lock()
+	defer z.namesMutex.Unlock()
+	return z.zones[path]
+}
+
+// nameDecoding returns how an entry name was decoded, if it was
+func (z *Extractor) nameDecoding(name string) (NameDecoding, bool) {
+	z.namesMutex.Lock()
+	defer z.namesMutex.Unlock()
+	decoding, ok := z.decodings[name]
+	return decoding, ok
+}
@@ -0,0 +1,63 @@
+package takeout
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGuessCharset(t *testing.T) {
+	tests := []struct {
+		raw  []string
+		want Charset
+	}{
+		{[]string{"Caf\x82/men\x81.txt"}, CharsetCP437},
+		{[]string{"Caf\xe9/r\xe9sum\xe9.txt"}, CharsetWindows1252},
+		{[]string{"\x82\xa0\x82\xa2.txt"}, CharsetShiftJIS},
+	}
+	for _, tt := range tests {
+		if got := guessCharset(tt.raw); got != tt.want {
+			t.Errorf("guessCharset(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+	}
+}
+
+// createRawZip writes entries whose names are stored as given, without the
+// UTF-8 flag
+func createRawZip(t *testing.T, names ...string) string {
+	t.Helper()
+	var headers []*zip.FileHeader
+	contents := map[string]string{}
+	for _, name := range names {
+		headers = append(headers, &zip.FileHeader{Name: name, Method: zip.Deflate, NonUTF8: true})
+		contents[name] = name
+	}
+	return createHeaderZip(t, headers, contents)
+}
+
+func TestUnzipDecodesNames(t *testing.T) {
+	zipPath := createRawZip(t, "Takeout/Drive/Caf\x82/men\x81.txt", "Takeout/Drive/plain.txt")
+
+	destDir := t.TempDir()
+	result, err := New(Options{Destination: destDir, BasePath: "Takeout/Drive/Café", Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Decoded != 1 || result.Extracted != 1 {
+		t.Errorf("Decoded %d and extracted %d, want 1 and 1", result.Decoded, result.Extracted)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "menü.txt")); err != nil {
+		t.Errorf("Decoded name not written: %v", err)
+	}
+
+	// A configured charset is used even when the guess would differ
+	zipPath = createRawZip(t, "Caf\xe9.txt")
+	destDir = t.TempDir()
+	if _, err := New(Options{Destination: destDir, Charset: CharsetCP437, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "CafΘ.txt")); err != nil {
+		t.Errorf("CP437 name not written: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package takeout
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultHashThreshold is the size from which files are no longer hashed
+const DefaultHashThreshold = 10 * 1024 * 1024
+
+// Comparator decides whether a file at the destination matches a zip entry,
+// and says why not when it doesn't
+type Comparator interface {
+	Equal(f *zip.File, destPath string) (bool, string)
+}
+
+// Check is one comparison a Strategy makes
+type Check string
+
+const (
+	CheckSize   Check = "size"   // Sizes are equal
+	CheckMtime  Check = "mtime"  // Modification times match within their precision
+	CheckCRC    Check = "crc"    // The CRC-32 of the file matches the one stored in the zip
+	CheckSHA256 Check = "sha256" // The SHA-256 of the file matches that of the entry
+)
+
+// Strategy is a Comparator that makes a set of checks in order, cheapest
+// first. Content checks only apply to files smaller than HashThreshold, or to
+// all files when it is zero
+type Strategy struct {
+	Checks        []Check
+	HashThreshold int64
+	Cache         *HashCache // Remembers digests of destination files, may be nil
+}
+
+// DefaultStrategy compares size and modification time, and hashes files
+// below 10MB
+var DefaultStrategy = Strategy{
+	Checks:        []Check{CheckSize, CheckMtime, CheckSHA256},
+	HashThreshold: DefaultHashThreshold,
+}
+
+// ParseStrategy parses checks joined by "+", such as "size+mtime" or
+// "size+sha256"
+func ParseStrategy(spec string, hashThreshold int64) (Strategy, error) {
+	if hashThreshold < 0 {
+		return Strategy{}, fmt.Errorf("hash threshold can't be negative")
+	}
+	s := Strategy{HashThreshold: hashThreshold}
+	seen := map[Check]bool{}
+	for _, name := range strings.Split(spec, "+") {
+		check := Check(strings.ToLower(strings.TrimSpace(name)))
+		switch check {
+		case CheckSize, CheckMtime, CheckCRC, CheckSHA256:
+		default:
+			return Strategy{}, fmt.Errorf("unknown comparison %q, want size, mtime, crc or sha256 joined by +", name)
+		}
+		seen[check] = true
+	}
+	// Keep the cheapest checks first, whatever order they were given in
+	for _, check := range []Check{CheckSize, CheckMtime, CheckCRC, CheckSHA256} {
+		if seen[check] {
+			s.Checks = append(s.Checks, check)
+		}
+	}
+	return s, nil
+}
+
+// String returns the checks joined by "+", as ParseStrategy accepts them
+func (s Strategy) String() string {
+	names := make([]string, len(s.Checks))
+	for i, check := range s.Checks {
+		names[i] = string(check)
+	}
+	return strings.Join(names, "+")
+}
+
+// has reports whether the strategy makes a check
+func (s Strategy) has(check Check) bool {
+	for _, c := range s.Checks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// hashes reports whether the strategy compares the content of an entry
+func (s Strategy) hashes(f *zip.File) bool {
+	return (s.has(CheckCRC) || s.has(CheckSHA256)) &&
+		(s.HashThreshold == 0 || int64(f.UncompressedSize64) < s.HashThreshold)
+}
+
+// Equal compares a file with an entry. Symlinks are always compared by their
+// target
+func (s Strategy) Equal(f *zip.File, destPath string) (bool, string) {
+	if isSymlinkEntry(f) {
+		return compareSymlink(f, destPath)
+	}
+
+	destInfo, err := GetFileInfo(destPath)
+	if err != nil {
+		return false, fmt.Sprintf("error accessing file: %v", err)
+	}
+
+	for _, check := range s.Checks {
+		switch check {
+		case CheckSize:
+			if destInfo.Size != int64(f.UncompressedSize64) {
+				return false, fmt.Sprintf("size mismatch: zip=%d, existing=%d", f.UncompressedSize64, destInfo.Size)
+			}
+		case CheckMtime:
+			if !isTimeMatch(f, destInfo.ModTime) {
+				return false, fmt.Sprintf("time mismatch: zip=%v, existing=%v", f.Modified, destInfo.ModTime)
+			}
+		case CheckCRC:
+			if !s.hashes(f) {
+				continue
+			}
+			equal, err := compareFileCRC(f, destPath, s.Cache)
+			if err != nil {
+				return false, fmt.Sprintf("crc comparison error: %v", err)
+			}
+			if !equal {
+				return false, "content mismatch (different crc)"
+			}
+		case CheckSHA256:
+			if !s.hashes(f) {
+				continue
+			}
+			equal, err := compareCachedHash(f, destPath, s.Cache)
+			if err != nil {
+				return false, fmt.Sprintf("hash comparison error: %v", err)
+			}
+			if !equal {
+				return false, "content mismatch (different hash)"
+			}
+		}
+	}
+	return true, ""
+}
+
+// EqualContent compares a file with content split out of an entry, which is
+// written with modTime, making the same checks as Equal
+func (s Strategy) EqualContent(data []byte, modTime time.Time, destPath string) (bool, string) {
+	destInfo, err := GetFileInfo(destPath)
+	if err != nil {
+		return false, fmt.Sprintf("error accessing file: %v", err)
+	}
+
+	hashes := s.HashThreshold == 0 || int64(len(data)) < s.HashThreshold
+	for _, check := range s.Checks {
+		switch check {
+		case CheckSize:
+			if destInfo.Size != int64(len(data)) {
+				return false, fmt.Sprintf("size mismatch: new=%d, existing=%d", len(data), destInfo.Size)
+			}
+		case CheckMtime:
+			// Split out times are whole seconds at best
+			if destInfo.ModTime.Sub(modTime).Abs() > dosTimePrecision {
+				return false, fmt.Sprintf("time mismatch: new=%v, existing=%v", modTime, destInfo.ModTime)
+			}
+		case CheckCRC:
+			if !hashes {
+				continue
+			}
+			existing, err := s.Cache.digest(destPath)
+			if err != nil {
+				return false, fmt.Sprintf("crc comparison error: %v", err)
+			}
+			if existing.CRC32 != crc32.ChecksumIEEE(data) {
+				return false, "content mismatch (different crc)"
+			}
+		case CheckSHA256:
+			if !hashes {
+				continue
+			}
+			existing, err := s.Cache.digest(destPath)
+			if err != nil {
+				return false, fmt.Sprintf("hash comparison error: %v", err)
+			}
+			if existing.SHA256 != sha256.Sum256(data) {
+				return false, "content mismatch (different hash)"
+			}
+		}
+	}
+	return true, ""
+}
+
+// compareSymlink compares the target of a symlink entry with the link at
+// destPath
+func compareSymlink(f *zip.File, destPath string) (bool, string) {
+	target, err := readLinkTarget(f)
+	if err != nil {
+		return false, fmt.Sprintf("error reading symlink: %v", err)
+	}
+	existing, err := os.Readlink(destPath)
+	if err != nil {
+		return false, fmt.Sprintf("error accessing symlink: %v", err)
+	}
+	if existing != filepath.FromSlash(target) {
+		return false, fmt.Sprintf("symlink mismatch: zip=%s, existing=%s", target, existing)
+	}
+	return true, ""
+}
+
+// compareFileCRC compares the CRC-32 of a file with the one stored for the
+// entry, which needs no decompression
+func compareFileCRC(f *zip.File, destPath string, cache *HashCache) (bool, error) {
+	existing, err := cache.digest(destPath)
+	if err != nil {
+		return false, err
+	}
+	return existing.CRC32 == f.CRC32, nil
+}
+
+// hashedBy reports whether a comparator already compares the content of an
+// entry. Only strategies are known to
+func hashedBy(c Comparator, f *zip.File) bool {
+	s, ok := c.(Strategy)
+	return ok && s.hashes(f)
+}
+
+// hashCache returns the cache of the extractor's comparator, or nil
+func (z *Extractor) hashCache() *HashCache {
+	if s, ok := z.comparator.(Strategy); ok {
+		return s.Cache
+	}
+	return nil
+}
+
+// comparesTime reports whether a comparator takes modification times into
+// account, which quick estimates follow
+func comparesTime(c Comparator) bool {
+	s, ok := c.(Strategy)
+	return !ok || s.has(CheckMtime)
+}
@@ -0,0 +1,117 @@
+package takeout
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "size+mtime+sha256", want: "size+mtime+sha256"},
+		{spec: "sha256+SIZE", want: "size+sha256"},
+		{spec: "crc", want: "crc"},
+		{spec: "size+md5", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := ParseStrategy(tt.spec, DefaultHashThreshold)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStrategy(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s.String() != tt.want {
+			t.Errorf("ParseStrategy(%q) = %s, want %s", tt.spec, s, tt.want)
+		}
+	}
+	if _, err := ParseStrategy("size", -1); err == nil {
+		t.Error("ParseStrategy should refuse a negative hash threshold")
+	}
+}
+
+func TestStrategyEqual(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "hello", modTime: modTime}})
+	defer os.Remove(zipPath)
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	f := r.File[0]
+
+	dir := t.TempDir()
+	touched := filepath.Join(dir, "touched.txt") // Same content, synced with a new time
+	os.WriteFile(touched, []byte("hello"), 0644)
+	changed := filepath.Join(dir, "changed.txt") // Same size and time, other content
+	os.WriteFile(changed, []byte("jello"), 0644)
+	os.Chtimes(changed, modTime, modTime)
+
+	tests := []struct {
+		spec      string
+		threshold int64
+		destPath  string
+		want      bool
+		reason    string
+	}{
+		{"size+mtime+sha256", DefaultHashThreshold, touched, false, "time mismatch"},
+		{"size+sha256", DefaultHashThreshold, touched, true, ""},
+		{"size+crc", DefaultHashThreshold, touched, true, ""},
+		{"size+mtime", DefaultHashThreshold, changed, true, ""},
+		{"size+mtime+sha256", DefaultHashThreshold, changed, false, "different hash"},
+		{"size+crc", DefaultHashThreshold, changed, false, "different crc"},
+		{"size+crc", 4, changed, true, ""}, // Above the threshold, so not hashed
+		{"size+crc", 0, changed, false, "different crc"},
+	}
+	for _, tt := range tests {
+		s, err := ParseStrategy(tt.spec, tt.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal, reason := s.Equal(f, tt.destPath)
+		if equal != tt.want || !strings.Contains(reason, tt.reason) {
+			t.Errorf("%s (threshold %d) on %s = %v, %q; want %v, %q",
+				tt.spec, tt.threshold, filepath.Base(tt.destPath), equal, reason, tt.want, tt.reason)
+		}
+		// Split out files follow the same strategy
+		equal, reason = New(Options{Compare: s}).isContentEqual([]byte("hello"), modTime, tt.destPath)
+		if equal != tt.want || !strings.Contains(reason, tt.reason) {
+			t.Errorf("content %s (threshold %d) on %s = %v, %q; want %v, %q",
+				tt.spec, tt.threshold, filepath.Base(tt.destPath), equal, reason, tt.want, tt.reason)
+		}
+	}
+}
+
+func TestExtractWithStrategy(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "hello", modTime: modTime}})
+	defer os.Remove(zipPath)
+	destDir := t.TempDir()
+	destPath := filepath.Join(destDir, "a.txt")
+	os.WriteFile(destPath, []byte("hello"), 0644)
+
+	s, _ := ParseStrategy("size+sha256", DefaultHashThreshold)
+	result, err := New(Options{Destination: destDir, Compare: s}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Skipped != 1 {
+		t.Errorf("skipped %d files, want the touched file skipped", result.Skipped)
+	}
+
+	plan, err := New(Options{Destination: destDir, Compare: s}).Plan([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := plan.Comparator()
+	if err != nil || c.(Strategy).String() != "size+sha256" {
+		t.Errorf("plan comparator = %v, %v; want size+sha256", c, err)
+	}
+}
@@ -0,0 +1,171 @@
+package takeout
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DiffEntry is a single difference between the destination and the archives
+type DiffEntry struct {
+	Path    string `json:"path"`              // Path relative to the destination
+	Archive string `json:"archive,omitempty"` // Archive providing the entry
+	Entry   string `json:"entry,omitempty"`   // Path within the zip
+	Reason  string `json:"reason,omitempty"`
+}
+
+// DiffReport lists how a destination folder differs from a set of archives
+type DiffReport struct {
+	Destination string      `json:"destination"`
+	Identical   int         `json:"identical"`
+	Missing     []DiffEntry `json:"missing"`  // In an archive but not on disk
+	Changed     []DiffEntry `json:"changed"`  // Content differs
+	Metadata    []DiffEntry `json:"metadata"` // Same content, different timestamps
+	Extra       []DiffEntry `json:"extra"`    // On disk but in no archive
+}
+
+// InSync reports whether the destination matches the archives exactly
+func (r *DiffReport) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Changed) == 0 && len(r.Metadata) == 0 && len(r.Extra) == 0
+}
+
+// diffSource is the archive entry that ends up at a destination path; when
+// several archives contain the same path the last one wins, as in extraction
+type diffSource struct {
+	archive string
+	file    *zip.File
+}
+
+// Diff compares the extractor's destination folder against the archives.
+// Files the comparator doesn't hash, such as those at or above its hash
+// threshold, are only hashed when hashAll is set. Files in keep, such as the
+// config file, are never reported as extra
+func (z *Extractor) Diff(zipFiles []string, hashAll bool, keep []string) (*DiffReport, error) {
+	report := &DiffReport{Destination: z.destFolder}
+	sources := map[string]diffSource{}
+	provided := map[string]bool{} // Every entry's destination, filtered or not
+	derived := map[string]bool{}
+
+	var readers []*zip.ReadCloser
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	for _, zipFile := range zipFiles {
+		r, err := z.OpenArchive(zipFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", zipFile, err)
+		}
+		readers = append(readers, r)
+		for _, f := range r.File {
+			destPath, ok := z.EntryPath(f.Name)
+			if !ok || f.FileInfo().IsDir() {
+				continue
+			}
+			provided[destPath] = true
+			if z.splitMbox && isMboxEntry(f.Name) || z.splitItems && isItemsEntry(f.Name) {
+				derived[derivedFolder(destPath)] = true
+			}
+			if z.splitMbox && isMboxEntry(f.Name) || !z.matchesFilters(f.Name) {
+				continue
+			}
+			sources[destPath] = diffSource{archive: zipFile, file: f}
+		}
+	}
+
+	for _, path := range keep {
+		if abs, err := filepath.Abs(path); err == nil {
+			provided[abs] = true
+		}
+	}
+
+	paths := make([]string, 0, len(sources))
+	for destPath := range sources {
+		paths = append(paths, destPath)
+	}
+	sort.Strings(paths)
+
+	for _, destPath := range paths {
+		src := sources[destPath]
+		entry := DiffEntry{Path: z.displayPath(destPath), Archive: src.archive, Entry: src.file.Name}
+		if !FileExists(destPath) {
+			report.Missing = append(report.Missing, entry)
+			continue
+		}
+
+		equal, reason := z.IsFileEqual(src.file, destPath)
+		if equal && hashAll && !hashedBy(z.comparator, src.file) {
+			if same, err := compareCachedHash(src.file, destPath, z.hashCache()); err != nil || !same {
+				equal, reason = false, "content mismatch (different hash)"
+			}
+		}
+		switch {
+		case equal:
+			report.Identical++
+		case isTimeMismatch(src.file, destPath):
+			// Size matches, so check whether only the timestamps differ
+			if same, err := compareCachedHash(src.file, destPath, z.hashCache()); err == nil && same {
+				entry.Reason = reason
+				report.Metadata = append(report.Metadata, entry)
+			} else {
+				entry.Reason = "content mismatch (different hash)"
+				report.Changed = append(report.Changed, entry)
+			}
+		default:
+			entry.Reason = reason
+			report.Changed = append(report.Changed, entry)
+		}
+	}
+
+	for _, root := range z.destinationRoots() {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			// Everything mapped here is already reported as missing
+			continue
+		}
+		trashRoot := filepath.Join(root, trashFolderName)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path == trashRoot || derived[path] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			abs, _ := filepath.Abs(path)
+			if !provided[path] && !provided[abs] {
+				report.Extra = append(report.Extra, DiffEntry{Path: z.displayPath(path)})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanning destination: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// isTimeMismatch reports whether a destination file has the entry's size but
+// a modification time outside the comparison tolerance
+func isTimeMismatch(f *zip.File, destPath string) bool {
+	info, err := GetFileInfo(destPath)
+	if err != nil || info.Size != int64(f.UncompressedSize64) {
+		return false
+	}
+	return !isTimeMatch(f, info.ModTime)
+}
+
+// RelativeTo returns path relative to base with forward slashes, or path
+// itself when it can't be made relative
+func RelativeTo(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,72 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffDestination(t *testing.T) {
+	destDir := t.TempDir()
+	testTime := time.Now().Add(-time.Hour).Round(time.Second)
+	zipPath := createTestZip(t, []testFile{
+		{name: "same.txt", content: "same", modTime: testTime},
+		{name: "missing.txt", content: "missing", modTime: testTime},
+		{name: "changed.txt", content: "original", modTime: testTime},
+		{name: "touched.txt", content: "touched", modTime: testTime},
+	})
+	defer os.Remove(zipPath)
+
+	write := func(name, content string, modTime time.Time) {
+		path := filepath.Join(destDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("same.txt", "same", testTime)
+	write("changed.txt", "modified", testTime)
+	write("touched.txt", "touched", testTime.Add(time.Hour))
+	write("sub/extra.txt", "extra", testTime)
+
+	extractor := New(Options{Destination: destDir, DryRun: true})
+	report, err := extractor.Diff([]string{zipPath}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(kind string, got []DiffEntry, want string) {
+		t.Helper()
+		if len(got) != 1 || got[0].Path != want {
+			t.Errorf("%s = %+v, want only %s", kind, got, want)
+		}
+	}
+	if report.Identical != 1 {
+		t.Errorf("Identical = %d, want 1", report.Identical)
+	}
+	check("Missing", report.Missing, "missing.txt")
+	check("Changed", report.Changed, "changed.txt")
+	check("Metadata", report.Metadata, "touched.txt")
+	check("Extra", report.Extra, "sub/extra.txt")
+	if report.InSync() {
+		t.Error("Report should not be in sync")
+	}
+}
+
+func TestDiffIgnoresTrashAndFilters(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 1)
+	if _, err := New(Options{Destination: destDir, Workers: 1}).Prune([]string{zipPath}, PruneOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	extractor := New(Options{Destination: destDir, Includes: []string{"dir"}, DryRun: true})
+	report, err := extractor.Diff([]string{zipPath}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.InSync() || report.Identical != 9 {
+		t.Errorf("report = %+v, want the 9 included files identical and nothing else", report)
+	}
+}
@@ -0,0 +1,784 @@
+// Package takeout extracts Google Takeout archives into a destination folder,
+// skipping files that are already extracted and unchanged.
+package takeout
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxRetries = 3
+const assumedExtractionSpeed = 100 * 1024 * 1024 // 100MB/s extraction speed assumption
+
+// Options configures an Extractor
+type Options struct {
+	Destination string   // Folder to extract into
+	BasePath    string   // Path within the zip to extract from
+	Workers     int      // Number of parallel extraction workers
+	AutoWorkers bool     // Pick the number of workers by measuring throughput, ignoring Workers
+	DryRun      bool     // Log what would happen without writing anything
+	SplitMbox   bool     // Split .mbox entries into per-message .eml files
+	SplitItems  bool     // Also split .vcf and .ics entries into per-item files
+	Includes    []string // Only extract entries under these zip paths
+	Excludes    []string // Skip entries under these zip paths
+	Mappings    []Mapping
+	Profile     NameProfile    // Filename rules of the destination, posix when empty
+	Normalize   Normalization  // Unicode form of destination names, none when empty
+	Charset     Charset        // Charset of names stored without the UTF-8 flag, auto when empty
+	NoSymlinks  bool           // Skip symlink entries instead of recreating them
+	Perms       PermPolicy     // Permissions of extracted files, preserve when empty
+	FileMode    os.FileMode    // File mode of PermsNormalize, DefaultFileMode when zero
+	DirMode     os.FileMode    // Folder mode of PermsNormalize, DefaultDirMode when zero
+	Owner       *Owner         // Owner given to extracted files, or nil to keep the current user
+	Metadata    bool           // Write metadata from Takeout sidecars as extended attributes
+	Timezone    *time.Location // Timezone of DOS timestamps, or nil to detect it per archive
+	Compare     Comparator     // Decides whether existing files match, DefaultStrategy when nil
+	MinFree     uint64         // Stop before free space at the destination drops below this
+	Throttle    *Throttle      // Limits how fast files are written, may be nil
+	Events      Events         // Receives progress, may be nil
+}
+
+// Events receives progress from an Extractor. Methods may be called from
+// several worker goroutines at once and must be safe for concurrent use
+type Events interface {
+	// ArchiveStarted is called before the entries of an archive are processed
+	ArchiveStarted(archive string, files int)
+	// EntryLogged is called for every log entry as it is recorded
+	EntryLogged(log ExtractionLog)
+	// FileDone is called when a file entry has been fully processed
+	FileDone(archive string, name string)
+	// ArchiveFinished is called after all entries of an archive are processed
+	ArchiveFinished(result *Result)
+}
+
+// NopEvents implements Events by ignoring them. Embed it to handle only
+// some of the events
+type NopEvents struct{}
+
+func (NopEvents) ArchiveStarted(string, int) {}
+func (NopEvents) EntryLogged(ExtractionLog)  {}
+func (NopEvents) FileDone(string, string)    {}
+func (NopEvents) ArchiveFinished(*Result)    {}
+
+// ExtractionLog represents a single file extraction attempt
+type ExtractionLog struct {
+	Archive   string    // Archive the entry came from, empty when not from an archive
+	Path      string    // Path within the zip
+	DestPath  string    // Destination path on disk
+	Size      int64     // File size
+	Status    string    // "Extracted", "Skipped", "Failed"
+	Reason    string    // Why it was skipped/failed, or empty for success
+	Timestamp time.Time // When the extraction was attempted
+	DryRun    bool      // Whether this was a dry run
+}
+
+// Result summarises the extraction of one archive
+type Result struct {
+	Archive      string
+	Files        int // File entries processed
+	Extracted    int // Files written, including replacements
+	Replaced     int // Existing files that were overwritten
+	Skipped      int // Files already present and unchanged
+	Failed       int // Files that could not be written
+	Stopped      int // Files not written because the destination ran low on space
+	Renamed      int // Files whose destination name was rewritten
+	Decoded      int // Files whose name was stored without the UTF-8 flag and decoded
+	WouldExtract int // Files a dry run would write
+	BytesWritten int64
+	Errors       []error
+	Logs         []ExtractionLog
+}
+
+// Err returns the first extraction error, or nil if all files succeeded
+func (r *Result) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to extract some files: %w", r.Errors[0])
+}
+
+// Extractor extracts zip archives into a destination folder
+type Extractor struct {
+	workers          int
+	tuner            *tuner // Picks the number of workers with AutoWorkers
+	throttle         *Throttle
+	dryRun           bool
+	destFolder       string
+	basePath         string
+	splitMbox        bool
+	splitItems       bool
+	includes         []string
+	excludes         []string
+	mappings         []Mapping
+	profile          NameProfile
+	namesMutex       sync.Mutex
+	renames          map[string]string // Zip path -> why its destination name differs
+	claimed          map[string]string // Lowercased destination -> zip path that uses it
+	caseFolding      map[string]bool   // Destination root -> whether its filesystem ignores case
+	normalization    Normalization
+	charset          Charset
+	decodings        map[string]NameDecoding // Decoded entry name -> how it was decoded
+	noSymlinks       bool
+	perms            PermPolicy
+	fileMode         os.FileMode
+	dirMode          os.FileMode
+	owner            *Owner
+	metadata         bool
+	timezone         *time.Location
+	zones            map[string]string // Archive -> timezone its DOS times were read in
+	comparator       Comparator
+	folderTimesMutex sync.Mutex
+	folderTimes      map[string]folderTime // Destination folder -> time to restore
+	folderMutex      sync.Mutex
+	folders          map[string]map[string]string // Folder -> NFC name -> name on disk
+	minFree          uint64
+	stopped          atomic.Bool // Set when the destination ran low on space
+	events           Events
+	logs             []ExtractionLog
+	logsMutex        sync.Mutex // Add mutex for logs
+}
+
+// New returns an Extractor configured by opts
+func New(opts Options) *Extractor {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	events := opts.Events
+	if events == nil {
+		events = NopEvents{}
+	}
+	z := &Extractor{
+		workers:       workers,
+		dryRun:        opts.DryRun,
+		destFolder:    opts.Destination,
+		basePath:      filepath.Clean(opts.BasePath),
+		splitMbox:     opts.SplitMbox,
+		splitItems:    opts.SplitItems,
+		profile:       opts.Profile,
+		renames:       map[string]string{},
+		claimed:       map[string]string{},
+		caseFolding:   map[string]bool{},
+		normalization: opts.Normalize,
+		charset:       opts.Charset,
+		decodings:     map[string]NameDecoding{},
+		noSymlinks:    opts.NoSymlinks,
+		perms:         opts.Perms,
+		fileMode:      opts.FileMode,
+		dirMode:       opts.DirMode,
+		owner:         opts.Owner,
+		metadata:      opts.Metadata,
+		timezone:      opts.Timezone,
+		zones:         map[string]string{},
+		comparator:    opts.Compare,
+		folderTimes:   map[string]folderTime{},
+		folders:       map[string]map[string]string{},
+		minFree:       opts.MinFree,
+		throttle:      opts.Throttle,
+		events:        events,
+	}
+	if opts.AutoWorkers {
+		z.tuner = newTuner()
+	}
+	if z.profile == "" {
+		z.profile = ProfilePOSIX
+	}
+	if z.normalization == "" {
+		z.normalization = NormalizeNone
+	}
+	if z.charset == "" {
+		z.charset = CharsetAuto
+	}
+	if z.comparator == nil {
+		z.comparator = DefaultStrategy
+	}
+	if z.perms == "" {
+		z.perms = PermsPreserve
+	}
+	if z.fileMode == 0 {
+		z.fileMode = DefaultFileMode
+	}
+	if z.dirMode == 0 {
+		z.dirMode = DefaultDirMode
+	}
+	z.SetFilters(opts.Includes, opts.Excludes)
+	if len(opts.Mappings) > 0 {
+		z.mappings = cleanMappings(opts.Mappings)
+	} else {
+		z.mappings = cleanMappings([]Mapping{{Source: opts.BasePath, Destination: opts.Destination}})
+	}
+	return z
+}
+
+// Options returns the options the Extractor currently uses
+func (z *Extractor) Options() Options {
+	return Options{
+		Destination: z.destFolder,
+		BasePath:    z.basePath,
+		Workers:     z.workers,
+		AutoWorkers: z.tuner != nil,
+		DryRun:      z.dryRun,
+		SplitMbox:   z.splitMbox,
+		SplitItems:  z.splitItems,
+		Includes:    append([]string(nil), z.includes...),
+		Excludes:    append([]string(nil), z.excludes...),
+		Mappings:    z.Mappings(),
+		Profile:     z.profile,
+		Normalize:   z.normalization,
+		Charset:     z.charset,
+		NoSymlinks:  z.noSymlinks,
+		Perms:       z.perms,
+		FileMode:    z.fileMode,
+		DirMode:     z.dirMode,
+		Owner:       z.owner,
+		Metadata:    z.metadata,
+		Timezone:    z.timezone,
+		Compare:     z.comparator,
+		MinFree:     z.minFree,
+		Throttle:    z.throttle,
+		Events:      z.events,
+	}
+}
+
+// Workers returns the number of workers extraction runs with. With
+// AutoWorkers it is the level picked so far, and settled is false while
+// other levels are still being measured
+func (z *Extractor) Workers() (workers int, settled bool) {
+	if z.tuner == nil {
+		return z.workers, true
+	}
+	return z.tuner.Workers()
+}
+
+type Duration struct {
+	Hours   int64
+	Minutes int64
+	Seconds int64
+}
+
+// FormatDuration splits a number of seconds into hours, minutes and seconds
+func FormatDuration(seconds int64) Duration {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	remainingSeconds := seconds % 60
+
+	return Duration{
+		Hours:   hours,
+		Minutes: minutes,
+		Seconds: remainingSeconds,
+	}
+}
+
+// TotalSeconds returns the duration in seconds
+func (d Duration) TotalSeconds() int64 {
+	return d.Hours*3600 + d.Minutes*60 + d.Seconds
+}
+
+type ZipSummary struct {
+	Path             string
+	TotalFiles       int
+	AlreadyExtracted int
+	Outdated         int    // Already extracted files whose size or time differ
+	BytesToWrite     int64  // Size of the new files
+	BytesToReplace   int64  // Size of the outdated files, which are written again
+	Timezone         string // Timezone DOS timestamps were read in, empty when none have only one
+	EstimatedTime    Duration
+}
+
+// FileInfo holds metadata about a file
+type FileInfo struct {
+	Size    int64
+	ModTime time.Time
+	Mode    os.FileMode
+}
+
+// GetFileInfo returns size, modification time and mode of a file
+func GetFileInfo(path string) (*FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("path is a directory")
+	}
+	return &FileInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}, nil
+}
+
+// IsFileEqual checks if a file at destPath matches the expected zip file
+// entry, using DefaultStrategy
+func IsFileEqual(f *zip.File, destPath string) (bool, string) {
+	return DefaultStrategy.Equal(f, destPath)
+}
+
+// IsFileEqual checks if a file at destPath matches the expected zip file
+// entry, using the extractor's comparator
+func (z *Extractor) IsFileEqual(f *zip.File, destPath string) (bool, string) {
+	return z.comparator.Equal(f, destPath)
+}
+
+// IsContentEqual checks if a file at destPath matches in-memory content that
+// would be written with the given modification time, using DefaultStrategy
+func IsContentEqual(data []byte, modTime time.Time, destPath string) (bool, string) {
+	return DefaultStrategy.EqualContent(data, modTime, destPath)
+}
+
+// isContentEqual compares content with a file at destPath using the
+// extractor's strategy, or DefaultStrategy for other comparators, which only
+// know how to compare zip entries
+func (z *Extractor) isContentEqual(data []byte, modTime time.Time, destPath string) (bool, string) {
+	s, ok := z.comparator.(Strategy)
+	if !ok {
+		s = DefaultStrategy
+	}
+	return s.EqualContent(data, modTime, destPath)
+}
+
+func compareFileHash(f *zip.File, destPath string) (bool, error) {
+	return compareCachedHash(f, destPath, nil)
+}
+
+// compareCachedHash compares the SHA-256 of an entry with that of the file at
+// destPath, taken from the cache when the file is unchanged
+func compareCachedHash(f *zip.File, destPath string, cache *HashCache) (bool, error) {
+	// Hash existing file first, as a missing file needs no decompression
+	existing, err := cache.digest(destPath)
+	if err != nil {
+		return false, err
+	}
+
+	// Hash zip file content
+	h := sha256.New()
+	rc, err := f.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(h, rc); err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(h.Sum(nil), existing.SHA256[:]), nil
+}
+
+// FileExists reports whether something other than a folder exists at path.
+// Symlinks count, even when their target is missing
+func FileExists(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && !info.IsDir()
+}
+
+// SetFilters limits extraction to entries under one of the include paths (or
+// all entries when there are none) that are not under any exclude path
+func (z *Extractor) SetFilters(includes, excludes []string) {
+	z.includes = cleanFilterPaths(includes)
+	z.excludes = cleanFilterPaths(excludes)
+}
+
+func cleanFilterPaths(paths []string) []string {
+	var cleaned []string
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p != "" && p != "." {
+			cleaned = append(cleaned, p)
+		}
+	}
+	return cleaned
+}
+
+// HasPathPrefix reports whether zipPath is prefix itself or lies below it
+func HasPathPrefix(zipPath, prefix string) bool {
+	zipPath = strings.TrimSuffix(zipPath, "/")
+	return zipPath == prefix || strings.HasPrefix(zipPath, prefix+"/")
+}
+
+func (z *Extractor) matchesFilters(zipPath string) bool {
+	return MatchesFilters(zipPath, z.includes, z.excludes)
+}
+
+// MatchesFilters reports whether zipPath is extracted with the given filters:
+// it is under no exclude path, whatever the includes, and under one of the
+// include paths, or there are none
+func MatchesFilters(zipPath string, includes, excludes []string) bool {
+	for _, exclude := range excludes {
+		if HasPathPrefix(zipPath, exclude) {
+			return false
+		}
+	}
+	if len(includes) == 0 {
+		return true
+	}
+	for _, include := range includes {
+		if HasPathPrefix(zipPath, include) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldIncludeFile returns the destination path of a zip entry that passes
+// the filters and is covered by a mapping
+func (z *Extractor) shouldIncludeFile(zipPath string) (string, bool) {
+	if !z.matchesFilters(zipPath) {
+		return "", false
+	}
+	return z.mapEntry(zipPath)
+}
+
+// EntryPath returns the destination path of a zip entry, ignoring filters. It
+// reports false for entries outside the base path or any mapping
+func (z *Extractor) EntryPath(zipPath string) (string, bool) {
+	return z.mapEntry(zipPath)
+}
+
+func (z *Extractor) EstimateTime(zipPath string) (*ZipSummary, error) {
+	r, err := z.OpenArchive(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+
+	summary := &ZipSummary{Path: zipPath, Timezone: z.archiveTimezone(zipPath)}
+	for _, f := range r.File {
+		destPath, include := z.shouldIncludeFile(f.Name)
+		if !include {
+			continue
+		}
+
+		summary.TotalFiles++
+		if FileExists(destPath) {
+			summary.AlreadyExtracted++
+			// Only compare metadata here, content is checked during extraction
+			if isSymlinkEntry(f) {
+				if equal, _ := z.IsFileEqual(f, destPath); !equal {
+					summary.Outdated++
+					summary.BytesToReplace += int64(f.UncompressedSize64)
+				}
+			} else if info, err := GetFileInfo(destPath); err == nil && !f.FileInfo().IsDir() &&
+				(info.Size != int64(f.UncompressedSize64) || comparesTime(z.comparator) && !isTimeMatch(f, info.ModTime)) {
+				summary.Outdated++
+				summary.BytesToReplace += int64(f.UncompressedSize64)
+			}
+			continue
+		}
+		summary.BytesToWrite += int64(f.UncompressedSize64)
+	}
+
+	summary.EstimatedTime = FormatDuration(summary.BytesToWrite / assumedExtractionSpeed)
+	return summary, nil
+}
+
+// entryJob is a single file entry of an archive to process
+type entryJob struct {
+	archive  string
+	f        *zip.File
+	destPath string
+	sidecar  *zip.File // JSON sidecar with the entry's metadata, if any
+}
+
+// Unzip extracts an archive into the destination and returns a summary of
+// what happened to each file. The returned error is non-nil when the archive
+// can't be read or any file failed to extract
+func (z *Extractor) Unzip(zipPath string) (*Result, error) {
+	r, err := z.OpenArchive(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer r.Close()
+
+	jobs, dirs := z.archiveJobs(zipPath, r, z.sidecars([]*zip.ReadCloser{r}))
+	result := z.runArchives([]archiveJobs{{path: zipPath, jobs: jobs}})[0]
+	z.restoreFolderTimes(z.destinationRoots(), dirs, jobs)
+	return result, result.Err()
+}
+
+// archiveResult tallies the logs recorded for an archive
+func (z *Extractor) archiveResult(zipPath string, files int, errors []error) *Result {
+	result := &Result{Archive: zipPath, Files: files, Errors: errors}
+	for _, log := range z.GetLogs() {
+		if log.Archive != zipPath {
+			continue
+		}
+		result.Logs = append(result.Logs, log)
+		switch log.Status {
+		case "Extracted":
+			result.Extracted++
+			result.BytesWritten += log.Size
+		case "Replacing":
+			result.Replaced++
+		case "Skipped":
+			result.Skipped++
+		case "Failed":
+			result.Failed++
+		case "Stopped":
+			result.Stopped++
+		case "Renamed":
+			result.Renamed++
+		case "Decoded":
+			result.Decoded++
+		case "Would Extract":
+			result.WouldExtract++
+		}
+	}
+	return result
+}
+
+func (z *Extractor) logExtraction(archive, path, destPath string, size int64, status, reason string) {
+	log := ExtractionLog{
+		Archive:   archive,
+		Path:      path,
+		DestPath:  destPath,
+		Size:      size,
+		Status:    status,
+		Reason:    reason,
+		Timestamp: time.Now(),
+		DryRun:    z.dryRun,
+	}
+	z.logsMutex.Lock()
+	z.logs = append(z.logs, log)
+	z.logsMutex.Unlock()
+	z.events.EntryLogged(log)
+}
+
+func (z *Extractor) GetLogs() []ExtractionLog {
+	z.logsMutex.Lock()
+	defer z.logsMutex.Unlock()
+	// Return a copy to prevent external modifications
+	logsCopy := make([]ExtractionLog, len(z.logs))
+	copy(logsCopy, z.logs)
+	return logsCopy
+}
+
+// processEntry extracts a single zip entry, routing symlinks and entries that
+// are split into derived files (such as mbox archives) to their handlers.
+// Devices, pipes and sockets are never created
+func (z *Extractor) processEntry(job entryJob) error {
+	if isSymlinkEntry(job.f) {
+		return z.extractSymlink(job)
+	}
+	if kind := specialKind(job.f); kind != "" {
+		z.logExtraction(job.archive, job.f.Name, job.destPath, int64(job.f.UncompressedSize64), "Skipped", fmt.Sprintf("Refusing to create a %s", kind))
+		return nil
+	}
+	if z.splitMbox && isMboxEntry(job.f.Name) {
+		return z.extractMbox(job)
+	}
+	if err := z.extractFile(job); err != nil {
+		return err
+	}
+	if z.splitItems && isItemsEntry(job.f.Name) {
+		return z.splitItemsEntry(job)
+	}
+	return nil
+}
+
+func (z *Extractor) extractFile(job entryJob) error {
+	f, destPath := job.f, job.destPath
+	log := func(status, reason string) {
+		z.logExtraction(job.archive, f.Name, destPath, int64(f.UncompressedSize64), status, reason)
+	}
+
+	if z.dryRun {
+		equal, reason := z.IsFileEqual(f, destPath)
+		if equal {
+			log("Skipped", "File already exists and matches")
+			return nil
+		}
+		var extractReason string
+		if FileExists(destPath) {
+			extractReason = fmt.Sprintf("File exists but %s", reason)
+		} else {
+			extractReason = "File does not exist"
+		}
+		log("Would Extract", extractReason)
+		return nil
+	}
+
+	equal, reason := z.IsFileEqual(f, destPath)
+	if equal {
+		log("Skipped", z.withMetadata(job, "File already exists and matches"))
+		return nil
+	}
+	growth := int64(f.UncompressedSize64)
+	if info, err := GetFileInfo(destPath); err == nil {
+		growth -= info.Size
+	}
+	if err := z.ensureSpace(destPath, growth); err != nil {
+		log("Stopped", err.Error())
+		return err
+	}
+	if FileExists(destPath) {
+		log("Replacing", reason)
+	}
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err := z.extractEntry(f, destPath)
+		if err == nil {
+			log("Extracted", z.withMetadata(job, droppedModes(f)))
+			return nil
+		}
+		if isNoSpace(err) {
+			// Retrying can't help, and neither can the remaining entries
+			z.stopped.Store(true)
+			log("Stopped", err.Error())
+			return fmt.Errorf("%w: %v", ErrInsufficientSpace, err)
+		}
+		if attempt < maxRetries {
+			log("Retry", fmt.Sprintf("Attempt %d/%d failed: %v", attempt, maxRetries, err))
+		} else {
+			log("Failed", fmt.Sprintf("All %d attempts failed: %v", maxRetries, err))
+		}
+	}
+	return fmt.Errorf("failed after %d attempts: %s", maxRetries, destPath)
+}
+
+// writeDerivedFile writes content derived from the entry of job to destPath,
+// applying the same skip/replace semantics and logging as extractFile
+func (z *Extractor) writeDerivedFile(job entryJob, destPath string, data []byte, modTime time.Time) error {
+	size := int64(len(data))
+	log := func(status, reason string) {
+		z.logExtraction(job.archive, job.f.Name, destPath, size, status, reason)
+	}
+
+	equal, reason := z.isContentEqual(data, modTime, destPath)
+	if equal {
+		log("Skipped", "File already exists and matches")
+		return nil
+	}
+	if z.dryRun {
+		extractReason := "File does not exist"
+		if FileExists(destPath) {
+			extractReason = fmt.Sprintf("File exists but %s", reason)
+		}
+		log("Would Extract", extractReason)
+		return nil
+	}
+	if err := z.ensureSpace(destPath, size); err != nil {
+		log("Stopped", err.Error())
+		return err
+	}
+	if FileExists(destPath) {
+		log("Replacing", reason)
+	}
+
+	z.throttle.wait(size, true)
+	err := z.mkdirAll(filepath.Dir(destPath))
+	if err == nil {
+		err = writeFileWithTime(destPath, data, modTime)
+		z.hashCache().forget(destPath)
+	}
+	if err == nil {
+		err = z.applyOwnership(destPath, z.fileMode)
+	}
+	if err != nil {
+		if isNoSpace(err) {
+			z.stopped.Store(true)
+			log("Stopped", err.Error())
+			return fmt.Errorf("%w: %v", ErrInsufficientSpace, err)
+		}
+		log("Failed", err.Error())
+		return err
+	}
+	log("Extracted", "")
+	return nil
+}
+
+func writeFileWithTime(destPath string, data []byte, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(destPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(destPath, modTime, modTime); err != nil {
+		return fmt.Errorf("failed to set file times: %w", err)
+	}
+	return nil
+}
+
+// extractEntry writes a zip entry to destPath following the permission
+// policy and owner
+func (z *Extractor) extractEntry(f *zip.File, destPath string) error {
+	if err := z.mkdirAll(filepath.Dir(destPath)); err != nil {
+		return err
+	}
+	perm := z.filePerm(f)
+	waited := z.throttle.wait(0, true)
+	start := time.Now()
+	throttled, err := writeEntry(f, destPath, perm, z.throttle)
+	// Rewriting a file in place can keep its cache key, so drop the old digest
+	z.hashCache().forget(destPath)
+	if err != nil {
+		return err
+	}
+	switch {
+	case z.tuner == nil:
+	case waited+throttled > 0:
+		// The throttle, not the disk, decided how fast this was
+		z.tuner.restart()
+	default:
+		z.tuner.record(int64(f.UncompressedSize64), time.Since(start))
+	}
+	return z.applyOwnership(destPath, perm)
+}
+
+// ExtractAndVerify writes the content of a zip entry to destPath with its
+// permission bits and timestamps
+func ExtractAndVerify(f *zip.File, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return err
+	}
+	_, err := writeEntry(f, destPath, f.Mode().Perm(), nil)
+	return err
+}
+
+// writeEntry writes the content and timestamps of a zip entry to
+// destPath, creating the file with perm, as fast as throttle allows. It
+// returns how long it waited on the throttle
+func writeEntry(f *zip.File, destPath string, perm os.FileMode, throttle *Throttle) (time.Duration, error) {
+	// Replace a symlink rather than writing to wherever it points
+	if info, err := os.Lstat(destPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(destPath); err != nil {
+			return 0, err
+		}
+	}
+
+	srcFile, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer destFile.Close()
+
+	src := &throttledReader{r: srcFile, throttle: throttle}
+	if _, err := io.Copy(destFile, src); err != nil {
+		return src.waited, err
+	}
+
+	// Close the file before setting timestamps
+	destFile.Close()
+
+	// Preserve timestamps from the zip file, to the precision it has them
+	if err := applyEntryTimes(destPath, f); err != nil {
+		return src.waited, fmt.Errorf("failed to set file times: %w", err)
+	}
+
+	return src.waited, nil
+}
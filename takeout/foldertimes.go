@@ -0,0 +1,84 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// folderTime is the modification time a destination folder is given once its
+// files are written
+type folderTime struct {
+	modified time.Time
+	explicit bool // From a directory entry rather than the newest file in it
+}
+
+// noteFolderTime records the time of a folder. A directory entry's time wins
+// over the times of files, and otherwise the newest file wins, across every
+// archive the extractor processes
+func (z *Extractor) noteFolderTime(dir string, modified time.Time, explicit bool) {
+	z.folderTimesMutex.Lock()
+	defer z.folderTimesMutex.Unlock()
+	current, ok := z.folderTimes[dir]
+	switch {
+	case explicit:
+		z.folderTimes[dir] = folderTime{modified: modified, explicit: true}
+	case !ok || !current.explicit && modified.After(current.modified):
+		z.folderTimes[dir] = folderTime{modified: modified}
+	}
+}
+
+// restoreFolderTimes sets the modification times of the folders an archive
+// wrote to, deepest first, after all its files are written. Writing files
+// bumps folder times, which would otherwise all show the time of extraction.
+// The destination roots themselves are left alone
+func (z *Extractor) restoreFolderTimes(roots, dirs []string, jobs []entryJob) {
+	if z.dryRun {
+		return
+	}
+	touched := map[string]bool{}
+	below := func(path string, note func(dir string)) {
+		root := rootOf(roots, path)
+		if root == "" {
+			return
+		}
+		for dir := path; dir != root && strings.HasPrefix(dir, root+string(os.PathSeparator)); dir = filepath.Dir(dir) {
+			note(dir)
+		}
+	}
+	for _, dir := range dirs {
+		below(dir, func(dir string) { touched[dir] = true })
+	}
+	for _, job := range jobs {
+		modified := job.f.Modified
+		below(filepath.Dir(job.destPath), func(dir string) {
+			touched[dir] = true
+			z.noteFolderTime(dir, modified, false)
+		})
+	}
+
+	ordered := make([]string, 0, len(touched))
+	for dir := range touched {
+		ordered = append(ordered, dir)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		di, dj := strings.Count(ordered[i], string(os.PathSeparator)), strings.Count(ordered[j], string(os.PathSeparator))
+		if di != dj {
+			return di > dj
+		}
+		return ordered[i] < ordered[j]
+	})
+
+	z.folderTimesMutex.Lock()
+	defer z.folderTimesMutex.Unlock()
+	for _, dir := range ordered {
+		t, ok := z.folderTimes[dir]
+		if !ok || t.modified.IsZero() {
+			continue
+		}
+		// Best effort, like creating the folders
+		os.Chtimes(dir, t.modified, t.modified)
+	}
+}
@@ -0,0 +1,40 @@
+package takeout
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnzipRestoresFolderTimes(t *testing.T) {
+	dirTime := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	older := time.Date(2020, 1, 2, 8, 0, 0, 0, time.UTC)
+	newer := time.Date(2021, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	zipPath := createHeaderZip(t, []*zip.FileHeader{
+		{Name: "Photos/", Modified: dirTime},
+		{Name: "Photos/2020/a.jpg", Modified: older},
+		{Name: "Photos/2020/b.jpg", Modified: newer},
+		{Name: "Photos/2020/Trip/c.jpg", Modified: older},
+	}, map[string]string{"Photos/2020/a.jpg": "a", "Photos/2020/b.jpg": "b", "Photos/2020/Trip/c.jpg": "c"})
+
+	destDir := t.TempDir()
+	if _, err := New(Options{Destination: destDir, Workers: 4}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	for dir, want := range map[string]time.Time{
+		"Photos":           dirTime,
+		"Photos/2020":      newer,
+		"Photos/2020/Trip": older,
+	} {
+		info, err := os.Stat(filepath.Join(destDir, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(want) {
+			t.Errorf("%s modified %v, want %v", dir, info.ModTime().UTC(), want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package takeout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// hashCacheMaxAge is how long a digest is kept after it was last used
+const hashCacheMaxAge = 90 * 24 * time.Hour
+
+// HashCache remembers the digests of destination files, so unchanged files
+// are not read again on every run. Digests are keyed by the device, inode,
+// size and modification time of the file, so most changes to the file miss
+// the cache, and the digests of files the extractor writes are dropped. It is
+// safe for concurrent use
+type HashCache struct {
+	path      string
+	mutex     sync.Mutex
+	loaded    bool
+	entries   map[fileKey]*cachedDigest
+	forgotten map[fileKey]bool // Keys to drop from the file when saving
+	dirty     bool
+}
+
+// fileKey identifies a version of a file
+type fileKey struct {
+	Device  uint64
+	Inode   uint64
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+}
+
+// fileDigest is the content digest of a file
+type fileDigest struct {
+	SHA256 [sha256.Size]byte
+	CRC32  uint32
+}
+
+type cachedDigest struct {
+	digest fileDigest
+	used   time.Time
+}
+
+// hashCacheRecord is how a digest is stored in the cache file
+type hashCacheRecord struct {
+	Device  uint64    `json:"dev"`
+	Inode   uint64    `json:"ino"`
+	Size    int64     `json:"size"`
+	ModTime int64     `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+	CRC32   uint32    `json:"crc32"`
+	Used    time.Time `json:"used"`
+}
+
+// NewHashCache returns a cache stored at path. The file is read on first
+// use and written by Save
+func NewHashCache(path string) *HashCache {
+	return &HashCache{path: path, entries: map[fileKey]*cachedDigest{}, forgotten: map[fileKey]bool{}}
+}
+
+// DefaultHashCachePath returns where the hash cache is kept unless told
+// otherwise, in the user's cache folder
+func DefaultHashCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "unzip-takeout", "hashes.json"), nil
+}
+
+// Path returns the file the cache is stored in
+func (c *HashCache) Path() string {
+	return c.path
+}
+
+// load reads the cache file once. A missing or unreadable file starts an
+// empty cache, as every digest can be computed again
+func (c *HashCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	for key, entry := range readHashCache(c.path) {
+		c.entries[key] = entry
+	}
+}
+
+func readHashCache(path string) map[fileKey]*cachedDigest {
+	entries := map[fileKey]*cachedDigest{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entries
+	}
+	var records []hashCacheRecord
+	if json.Unmarshal(data, &records) != nil {
+		return entries
+	}
+	for _, r := range records {
+		sum, err := hex.DecodeString(r.SHA256)
+		if err != nil || len(sum) != sha256.Size {
+			continue
+		}
+		entry := &cachedDigest{digest: fileDigest{CRC32: r.CRC32}, used: r.Used}
+		copy(entry.digest.SHA256[:], sum)
+		entries[fileKey{r.Device, r.Inode, r.Size, r.ModTime}] = entry
+	}
+	return entries
+}
+
+// digest returns the digest of the file at path, from the cache when the
+// file hasn't changed since it was hashed. A nil cache always reads the file
+func (c *HashCache) digest(path string) (fileDigest, error) {
+	if c == nil {
+		return hashFile(path)
+	}
+	key, ok := fileKeyOf(path)
+	if ok {
+		c.mutex.Lock()
+		c.load()
+		entry, hit := c.entries[key]
+		if hit {
+			entry.used, c.dirty = time.Now(), true
+		}
+		c.mutex.Unlock()
+		if hit {
+			return entry.digest, nil
+		}
+	}
+
+	d, err := hashFile(path)
+	if err != nil || !ok {
+		return d, err
+	}
+	// Only remember the digest when the file didn't change while reading it
+	if after, same := fileKeyOf(path); same && after == key {
+		c.mutex.Lock()
+		c.entries[key] = &cachedDigest{digest: d, used: time.Now()}
+		delete(c.forgotten, key)
+		c.dirty = true
+		c.mutex.Unlock()
+	}
+	return d, nil
+}
+
+// forget drops the digest of the file at path, which was just written. A
+// file rewritten in place with the same size and time keeps its key, so the
+// old digest would otherwise be taken for the new content. A nil cache does
+// nothing
+func (c *HashCache) forget(path string) {
+	if c == nil {
+		return
+	}
+	key, ok := fileKeyOf(path)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.load()
+	delete(c.entries, key)
+	c.forgotten[key] = true
+	c.dirty = true
+}
+
+// Save writes the cache, merged with digests other runs saved in the
+// meantime. Digests unused for 90 days are dropped
+func (c *HashCache) Save() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	entries := readHashCache(c.path)
+	for key, entry := range c.entries {
+		if prev, ok := entries[key]; !ok || prev.used.Before(entry.used) {
+			entries[key] = entry
+		}
+	}
+	for key := range c.forgotten {
+		delete(entries, key)
+	}
+	records := make([]hashCacheRecord, 0, len(entries))
+	cutoff := time.Now().Add(-hashCacheMaxAge)
+	for key, entry := range entries {
+		if entry.used.Before(cutoff) {
+			continue
+		}
+		records = append(records, hashCacheRecord{
+			Device:  key.Device,
+			Inode:   key.Inode,
+			Size:    key.Size,
+			ModTime: key.ModTime,
+			SHA256:  hex.EncodeToString(entry.digest.SHA256[:]),
+			CRC32:   entry.digest.CRC32,
+			Used:    entry.used,
+		})
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".hashes-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	c.forgotten = map[fileKey]bool{}
+	return nil
+}
+
+// hashFile reads a file once for both its SHA-256 and CRC-32
+func hashFile(path string) (fileDigest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileDigest{}, err
+	}
+	defer file.Close()
+	sha, crc := sha256.New(), crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(sha, crc), file); err != nil {
+		return fileDigest{}, err
+	}
+	var d fileDigest
+	copy(d.SHA256[:], sha.Sum(nil))
+	d.CRC32 = crc.Sum32()
+	return d, nil
+}
+
+// fileKeyOf returns the key of the file at path, or false when the platform
+// doesn't identify files by device and inode
+func fileKeyOf(path string) (fileKey, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return fileKey{}, false
+	}
+	device, inode, err := fileID(path, info)
+	if err != nil {
+		return fileKey{}, false
+	}
+	return fileKey{device, inode, info.Size(), info.ModTime().UnixNano()}, true
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package takeout
+
+import (
+	"errors"
+	"os"
+)
+
+// fileID is not supported on this platform, so digests are not cached
+func fileID(path string, info os.FileInfo) (uint64, uint64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
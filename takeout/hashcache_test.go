@@ -0,0 +1,97 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	os.WriteFile(path, []byte("hello"), 0644)
+	os.Chtimes(path, modTime, modTime)
+	if _, ok := fileKeyOf(path); !ok {
+		t.Skip("files have no inode on this platform")
+	}
+	want, _ := hashFile(path)
+
+	cachePath := filepath.Join(dir, "cache", "hashes.json")
+	cache := NewHashCache(cachePath)
+	if d, err := cache.digest(path); err != nil || d != want {
+		t.Fatalf("digest = %x, %v; want %x", d.SHA256, err, want.SHA256)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewriting the file in place with the same size and time keeps its key,
+	// so a reloaded cache answers without reading it
+	os.WriteFile(path, []byte("jello"), 0644)
+	os.Chtimes(path, modTime, modTime)
+	reloaded := NewHashCache(cachePath)
+	if d, _ := reloaded.digest(path); d != want {
+		t.Error("reloaded cache should return the saved digest")
+	}
+
+	// Any change to the key reads the file again
+	os.Chtimes(path, modTime, modTime.Add(time.Second))
+	if d, _ := reloaded.digest(path); d == want {
+		t.Error("digest should be recomputed after the modification time changed")
+	}
+}
+
+func TestStrategyUsesHashCache(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "hello", modTime: modTime}})
+	defer os.Remove(zipPath)
+	destDir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "hashes.json")
+
+	s := DefaultStrategy
+	s.Cache = NewHashCache(cachePath)
+	for i := 0; i < 2; i++ {
+		if _, err := New(Options{Destination: destDir, Compare: s}).Unzip(zipPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	destPath := filepath.Join(destDir, "a.txt")
+	if _, ok := fileKeyOf(destPath); ok && len(readHashCache(cachePath)) != 1 {
+		t.Errorf("cache has %d digests, want the extracted file's", len(readHashCache(cachePath)))
+	}
+}
+
+func TestHashCacheAfterRewrite(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "hello", modTime: modTime}})
+	defer os.Remove(zipPath)
+	destDir := t.TempDir()
+	destPath := filepath.Join(destDir, "a.txt")
+	os.WriteFile(destPath, []byte("jello"), 0644)
+	os.Chtimes(destPath, modTime, modTime)
+	if _, ok := fileKeyOf(destPath); !ok {
+		t.Skip("files have no inode on this platform")
+	}
+	cachePath := filepath.Join(t.TempDir(), "hashes.json")
+
+	// The rewrite keeps the size, time and inode of the file, and so its key
+	for run, want := range []string{"Replacing", "Skipped"} {
+		s := DefaultStrategy
+		s.Cache = NewHashCache(cachePath)
+		result, err := New(Options{Destination: destDir, Compare: s}).Unzip(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Cache.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Logs) == 0 || result.Logs[0].Status != want {
+			t.Errorf("Run %d logged %+v, want %s first", run+1, result.Logs, want)
+		}
+	}
+}
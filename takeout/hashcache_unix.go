@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package takeout
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode of a file
+func fileID(path string, info os.FileInfo) (uint64, uint64, error) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, errors.ErrUnsupported
+	}
+	return uint64(sys.Dev), uint64(sys.Ino), nil
+}
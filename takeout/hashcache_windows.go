@@ -0,0 +1,29 @@
+//go:build windows
+
+package takeout
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileID returns the volume serial number and file index of a file, which
+// identify it as device and inode do elsewhere
+func fileID(path string, info os.FileInfo) (uint64, uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	h, err := windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer windows.CloseHandle(h)
+	var data windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &data); err != nil {
+		return 0, 0, err
+	}
+	return uint64(data.VolumeSerialNumber), uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow), nil
+}
@@ -0,0 +1,249 @@
+package takeout
+
+import (
+	"archive/zip"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EntryInfo describes a file or directory in an archive's central directory.
+// Directories are synthesised from entry names when the zip has no entry for them
+type EntryInfo struct {
+	Name           string    `json:"name"`
+	IsDir          bool      `json:"is_dir"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressed_size"`
+	Ratio          float64   `json:"ratio"`
+	Files          int       `json:"files,omitempty"`
+	Modified       time.Time `json:"modified"`
+	Mode           string    `json:"mode,omitempty"`
+	Method         string    `json:"method,omitempty"`
+	CRC32          string    `json:"crc32,omitempty"`
+}
+
+// TreeNode is an EntryInfo with its children, as printed by the tree command
+type TreeNode struct {
+	EntryInfo
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// ArchiveStat summarises an archive and its top-level Takeout product folders
+type ArchiveStat struct {
+	Path           string      `json:"path"`
+	Entries        int         `json:"entries"`
+	Files          int         `json:"files"`
+	Size           int64       `json:"size"`
+	CompressedSize int64       `json:"compressed_size"`
+	Ratio          float64     `json:"ratio"`
+	Oldest         time.Time   `json:"oldest"`
+	Newest         time.Time   `json:"newest"`
+	Products       []EntryInfo `json:"products"`
+}
+
+// compressionRatio returns how much smaller the compressed data is, from 0
+// (stored) towards 1
+func compressionRatio(size, compressed int64) float64 {
+	if size == 0 {
+		return 0
+	}
+	return 1 - float64(compressed)/float64(size)
+}
+
+func fileEntryInfo(f *zip.File) EntryInfo {
+	method := "store"
+	if f.Method == zip.Deflate {
+		method = "deflate"
+	} else if f.Method != zip.Store {
+		method = fmt.Sprintf("method %d", f.Method)
+	}
+	return EntryInfo{
+		Name:           strings.TrimSuffix(f.Name, "/"),
+		IsDir:          f.FileInfo().IsDir(),
+		Size:           int64(f.UncompressedSize64),
+		CompressedSize: int64(f.CompressedSize64),
+		Ratio:          compressionRatio(int64(f.UncompressedSize64), int64(f.CompressedSize64)),
+		Modified:       f.Modified,
+		Mode:           f.Mode().String(),
+		Method:         method,
+		CRC32:          fmt.Sprintf("%08x", f.CRC32),
+	}
+}
+
+// buildTree builds the directory tree of all entries under prefix
+func buildTree(files []*zip.File, prefix string) *TreeNode {
+	prefix = strings.Trim(prefix, "/")
+	root := &TreeNode{EntryInfo: EntryInfo{Name: prefix, IsDir: true}}
+	nodes := map[string]*TreeNode{prefix: root}
+	parents := map[*TreeNode]*TreeNode{}
+
+	var node func(path string) *TreeNode
+	node = func(path string) *TreeNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		parentPath := ""
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			parentPath = path[:i]
+		}
+		n := &TreeNode{EntryInfo: EntryInfo{Name: path, IsDir: true}}
+		nodes[path] = n
+		parent := node(parentPath)
+		parent.Children = append(parent.Children, n)
+		parents[n] = parent
+		return n
+	}
+
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name, "/")
+		if prefix != "" && !HasPathPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		info := fileEntryInfo(f)
+		if info.IsDir {
+			n := node(name)
+			n.Modified, n.Mode = info.Modified, info.Mode
+			continue
+		}
+
+		parentPath := ""
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			parentPath = name[:i]
+		}
+		parent := node(parentPath)
+		parent.Children = append(parent.Children, &TreeNode{EntryInfo: info})
+		for p := parent; p != nil; p = parents[p] {
+			p.Size += info.Size
+			p.CompressedSize += info.CompressedSize
+			p.Files++
+			if info.Modified.After(p.Modified) {
+				p.Modified = info.Modified
+			}
+		}
+	}
+
+	var finish func(n *TreeNode)
+	finish = func(n *TreeNode) {
+		n.Ratio = compressionRatio(n.Size, n.CompressedSize)
+		sort.Slice(n.Children, func(i, j int) bool {
+			if n.Children[i].IsDir != n.Children[j].IsDir {
+				return n.Children[i].IsDir
+			}
+			return n.Children[i].Name < n.Children[j].Name
+		})
+		for _, c := range n.Children {
+			finish(c)
+		}
+	}
+	finish(root)
+	return root
+}
+
+// StatArchive summarises an archive, with totals per top-level product
+// folder (such as "Takeout/Drive" or "Takeout/Google Photos")
+func StatArchive(zipPath string) (*ArchiveStat, error) {
+	r, _, err := openArchive(zipPath, CharsetAuto)
+	if err != nil {
+		return nil, fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+	fixDOSTimes(r.File, nil)
+
+	stat := &ArchiveStat{Path: zipPath, Entries: len(r.File)}
+	products := map[string]*EntryInfo{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		size, compressed := int64(f.UncompressedSize64), int64(f.CompressedSize64)
+		stat.Files++
+		stat.Size += size
+		stat.CompressedSize += compressed
+		if stat.Oldest.IsZero() || f.Modified.Before(stat.Oldest) {
+			stat.Oldest = f.Modified
+		}
+		if f.Modified.After(stat.Newest) {
+			stat.Newest = f.Modified
+		}
+
+		product := productFolder(f.Name)
+		p, ok := products[product]
+		if !ok {
+			p = &EntryInfo{Name: product, IsDir: true}
+			products[product] = p
+		}
+		p.Files++
+		p.Size += size
+		p.CompressedSize += compressed
+		if f.Modified.After(p.Modified) {
+			p.Modified = f.Modified
+		}
+	}
+	stat.Ratio = compressionRatio(stat.Size, stat.CompressedSize)
+
+	for _, p := range products {
+		p.Ratio = compressionRatio(p.Size, p.CompressedSize)
+		stat.Products = append(stat.Products, *p)
+	}
+	sort.Slice(stat.Products, func(i, j int) bool {
+		return stat.Products[i].Size > stat.Products[j].Size
+	})
+	return stat, nil
+}
+
+// productFolder returns the top-level Takeout product folder of an entry,
+// such as "Takeout/Drive", or the first path component for other archives
+func productFolder(name string) string {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) >= 3 && parts[0] == "Takeout" {
+		return parts[0] + "/" + parts[1]
+	}
+	if len(parts) == 1 {
+		return "."
+	}
+	return parts[0]
+}
+
+// Entries returns the direct children of a tree node without their children
+func (tree *TreeNode) Entries() []EntryInfo {
+	entries := make([]EntryInfo, 0, len(tree.Children))
+	for _, c := range tree.Children {
+		entries = append(entries, c.EntryInfo)
+	}
+	return entries
+}
+
+// StatEntry returns the details of a single entry, summarising directories
+// that have no entry of their own from their contents
+func StatEntry(zipPath, name string) (*EntryInfo, error) {
+	r, _, err := openArchive(zipPath, CharsetAuto)
+	if err != nil {
+		return nil, fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+	fixDOSTimes(r.File, nil)
+
+	for _, f := range r.File {
+		if strings.TrimSuffix(f.Name, "/") == name {
+			info := fileEntryInfo(f)
+			return &info, nil
+		}
+	}
+	tree := buildTree(r.File, name)
+	if len(tree.Children) == 0 {
+		return nil, fmt.Errorf("no entry %s", name)
+	}
+	return &tree.EntryInfo, nil
+}
+
+// ListTree returns the directory tree of the entries under prefix
+func ListTree(zipPath, prefix string) (*TreeNode, error) {
+	r, _, err := openArchive(zipPath, CharsetAuto)
+	if err != nil {
+		return nil, fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+	fixDOSTimes(r.File, nil)
+	return buildTree(r.File, prefix), nil
+}
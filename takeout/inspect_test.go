@@ -0,0 +1,58 @@
+package takeout
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// inspectFiles are the entries of the archive the inspect tests read
+var inspectFiles = []testFile{
+	{name: "Takeout/Drive", isDir: true},
+	{name: "Takeout/Drive/a.txt", content: strings.Repeat("a", 1000)},
+	{name: "Takeout/Drive/Docs/b.txt", content: strings.Repeat("b", 500)},
+	{name: "Takeout/Google Photos/c.jpg", content: "c"},
+	{name: "archive_browser.html", content: "<html>"},
+}
+
+func TestStatArchive(t *testing.T) {
+	zipPath := createTestZip(t, inspectFiles)
+	defer os.Remove(zipPath)
+
+	stat, err := StatArchive(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Entries != 5 || stat.Files != 4 {
+		t.Errorf("Entries/Files = %d/%d, want 5/4", stat.Entries, stat.Files)
+	}
+	if stat.Size != 1507 {
+		t.Errorf("Size = %d, want 1507", stat.Size)
+	}
+	if len(stat.Products) != 3 {
+		t.Fatalf("Got %d products, want 3: %+v", len(stat.Products), stat.Products)
+	}
+	drive := stat.Products[0]
+	if drive.Name != "Takeout/Drive" || drive.Files != 2 || drive.Size != 1500 {
+		t.Errorf("Largest product = %+v, want Takeout/Drive with 2 files and 1500 bytes", drive)
+	}
+	if drive.Ratio <= 0 {
+		t.Errorf("Repetitive content should compress, got ratio %v", drive.Ratio)
+	}
+}
+
+func TestListTree(t *testing.T) {
+	zipPath := createTestZip(t, inspectFiles)
+	defer os.Remove(zipPath)
+
+	tree, err := ListTree(zipPath, "Takeout/Drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Files != 2 || tree.Size != 1500 {
+		t.Errorf("Tree totals = %d files, %d bytes; want 2, 1500", tree.Files, tree.Size)
+	}
+	if len(tree.Children) != 2 || !tree.Children[0].IsDir || tree.Children[0].Name != "Takeout/Drive/Docs" {
+		t.Errorf("Expected Docs directory first, got %+v", tree.Children)
+	}
+}
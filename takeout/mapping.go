@@ -0,0 +1,164 @@
+package takeout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mapping sends the entries under a path within the zip to their own
+// destination folder, for example Google Photos to one drive and Drive to
+// another
+type Mapping struct {
+	Source      string // Path within the zip, or empty for every entry
+	Destination string // Folder the entries below Source are extracted into
+}
+
+// cleanMappings normalises the mapping sources and orders them longest first,
+// so the most specific mapping wins. Destinations are made absolute, so paths
+// below them can be matched to their root even when the destination is "."
+func cleanMappings(mappings []Mapping) []Mapping {
+	cleaned := make([]Mapping, 0, len(mappings))
+	for _, m := range mappings {
+		source := strings.Trim(filepath.ToSlash(filepath.Clean(m.Source)), "/")
+		if source == "." {
+			source = ""
+		}
+		dest := filepath.Clean(m.Destination)
+		if abs, err := filepath.Abs(dest); err == nil {
+			dest = abs
+		}
+		cleaned = append(cleaned, Mapping{Source: source, Destination: dest})
+	}
+	sort.SliceStable(cleaned, func(i, j int) bool {
+		return len(cleaned[i].Source) > len(cleaned[j].Source)
+	})
+	return cleaned
+}
+
+// mapEntry returns the destination path of a zip entry using the mapping with
+// the longest matching source. It reports false for entries no mapping covers
+func (z *Extractor) mapEntry(zipPath string) (string, bool) {
+	for _, m := range z.mappings {
+		relPath := zipPath
+		if m.Source != "" {
+			if !HasPathPrefix(zipPath, m.Source) {
+				continue
+			}
+			relPath = strings.TrimPrefix(strings.TrimPrefix(zipPath, m.Source), "/")
+		}
+		return z.existingVariant(filepath.Join(m.Destination, z.destName(zipPath, m.Destination, relPath))), true
+	}
+	return "", false
+}
+
+// destName sanitises the path of an entry below its mapping for the name
+// profile and normalization. On case-insensitive profiles or destinations a
+// file whose path only differs in case from an earlier file gets a hash of
+// its zip path added, so the two don't overwrite each other
+func (z *Extractor) destName(zipPath, root, relPath string) string {
+	sanitized := z.profile.SanitizePath(relPath)
+	name := z.normalization.apply(sanitized)
+	isFile := !strings.HasSuffix(zipPath, "/")
+
+	z.namesMutex.Lock()
+	defer z.namesMutex.Unlock()
+	if sanitized != relPath && isFile {
+		z.renames[zipPath] = fmt.Sprintf("Renamed for the %s profile: %s -> %s", z.profile, relPath, name)
+	} else if name != relPath && isFile {
+		z.renames[zipPath] = fmt.Sprintf("Renamed to %s normalization: %s -> %s", strings.ToUpper(string(z.normalization)), relPath, name)
+	}
+	if !isFile || !z.profile.caseInsensitive() && !z.foldsCase(root) {
+		return name
+	}
+
+	key := strings.ToLower(norm.NFC.String(filepath.Join(root, name)))
+	owner, claimed := z.claimed[key]
+	if !claimed || owner == zipPath {
+		z.claimed[key] = zipPath
+		return name
+	}
+	name = withHash(name, zipPath)
+	z.renames[zipPath] = fmt.Sprintf("Renamed, its name only differs in case from %s: %s -> %s", owner, relPath, name)
+	return name
+}
+
+// foldsCase reports whether the filesystem of a destination root treats
+// names differing only in case as the same, as APFS and NTFS do by default.
+// The caller holds namesMutex
+func (z *Extractor) foldsCase(root string) bool {
+	folds, ok := z.caseFolding[root]
+	if !ok {
+		folds = caseInsensitiveDir(existingAncestor(root))
+		z.caseFolding[root] = folds
+	}
+	return folds
+}
+
+// caseInsensitiveDir creates a probe file in dir and looks it up with its
+// name in upper case. Folders that can't be written to count as case
+// sensitive
+func caseInsensitiveDir(dir string) bool {
+	f, err := os.CreateTemp(dir, ".case-probe-")
+	if err != nil {
+		return false
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+	_, err = os.Stat(filepath.Join(dir, strings.ToUpper(filepath.Base(name))))
+	return err == nil
+}
+
+// renameReason returns why an entry's destination name differs from its
+// name within the zip, if it does
+func (z *Extractor) renameReason(zipPath string) (string, bool) {
+	z.namesMutex.Lock()
+	defer z.namesMutex.Unlock()
+	reason, ok := z.renames[zipPath]
+	return reason, ok
+}
+
+// Mappings returns the mappings in the order they are matched
+func (z *Extractor) Mappings() []Mapping {
+	return append([]Mapping(nil), z.mappings...)
+}
+
+// destinationRoots returns the distinct mapping destinations, leaving out any
+// folder that lies inside another one so each file is visited once
+func (z *Extractor) destinationRoots() []string {
+	var roots []string
+	for _, m := range z.mappings {
+		roots = append(roots, m.Destination)
+	}
+	sort.Strings(roots)
+
+	var distinct []string
+	for _, root := range roots {
+		nested := false
+		for _, kept := range distinct {
+			if root == kept || strings.HasPrefix(root, kept+string(os.PathSeparator)) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			distinct = append(distinct, root)
+		}
+	}
+	return distinct
+}
+
+// displayPath returns a destination path relative to the root it lies in,
+// or the full path when files go to more than one destination
+func (z *Extractor) displayPath(path string) string {
+	roots := z.destinationRoots()
+	if len(roots) == 1 {
+		return RelativeTo(roots[0], path)
+	}
+	return path
+}
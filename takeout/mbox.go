@@ -0,0 +1,239 @@
+package takeout
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// unlabeledFolder holds messages with no label to sort them by
+const unlabeledFolder = "Unlabeled"
+
+// gmailMailboxes are system labels that still make a sensible folder, for
+// messages with no label of their own
+var gmailMailboxes = map[string]bool{
+	"Inbox": true, "Sent": true, "Drafts": true, "Spam": true, "Trash": true, "Chat": true,
+}
+
+// gmailFlags are system labels that only mark a state, and say nothing about
+// where a message belongs. Gmail also adds "Category ..." labels for its tabs
+var gmailFlags = map[string]bool{
+	"Opened": true, "Unread": true, "Important": true, "Starred": true, "Archived": true,
+}
+
+// isMboxEntry reports whether a zip entry is an mbox archive
+func isMboxEntry(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".mbox")
+}
+
+// derivedFolder returns the directory that files split out of the entry at
+// destPath are written to
+func derivedFolder(destPath string) string {
+	return strings.TrimSuffix(destPath, filepath.Ext(destPath))
+}
+
+// extractMbox streams an mbox entry out of the zip and writes each message as
+// a separate .eml file, organised into folders by its X-Gmail-Labels header
+func (z *Extractor) extractMbox(job entryJob) error {
+	f := job.f
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	folder := derivedFolder(job.destPath)
+	var failed int
+	seen := map[string]int{}
+	err = readMboxMessages(rc, func(msg []byte) error {
+		path, modTime := z.messagePath(folder, msg, f.Modified)
+		// Gmail can export a message twice, so number the later copies
+		// rather than overwriting the first
+		seen[path]++
+		if n := seen[path]; n > 1 {
+			path = fmt.Sprintf("%s_%d.eml", strings.TrimSuffix(path, ".eml"), n)
+		}
+		if err := z.writeDerivedFile(job, path, msg, modTime); err != nil {
+			if errors.Is(err, ErrInsufficientSpace) {
+				// No later message fits either, so stop reading
+				return err
+			}
+			failed++
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInsufficientSpace) {
+		return fmt.Errorf("splitting %s: %w", f.Name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("reading mbox: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to write %d messages from %s", failed, f.Name)
+	}
+	return nil
+}
+
+// readMboxMessages reads an mbox stream and calls fn with the raw bytes of each
+// message, with the "From " separator removed and mboxrd quoting undone
+func readMboxMessages(r io.Reader, fn func(msg []byte) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var msg bytes.Buffer
+	inMessage := false
+	prevBlank := true
+	lastLen := 0
+
+	flush := func() error {
+		if !inMessage {
+			return nil
+		}
+		// Drop the blank line separating this message from the next
+		data := msg.Bytes()
+		if prevBlank {
+			data = data[:len(data)-lastLen]
+		}
+		out := make([]byte, len(data))
+		copy(out, data)
+		msg.Reset()
+		return fn(out)
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if prevBlank && bytes.HasPrefix(line, []byte("From ")) {
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+				inMessage = true
+				lastLen = 0
+			} else if inMessage {
+				line = unquoteFromLine(line)
+				msg.Write(line)
+				lastLen = len(line)
+			}
+			prevBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// unquoteFromLine undoes mboxrd escaping of lines that start with ">From "
+func unquoteFromLine(line []byte) []byte {
+	trimmed := bytes.TrimLeft(line, ">")
+	if len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+		return line[1:]
+	}
+	return line
+}
+
+// messagePath returns where a message is stored under folder and the
+// modification time it should get, falling back to fallbackTime when the
+// message has no parseable Date header
+func (z *Extractor) messagePath(folder string, msg []byte, fallbackTime time.Time) (string, time.Time) {
+	var header mail.Header
+	if m, err := mail.ReadMessage(bytes.NewReader(msg)); err == nil {
+		header = m.Header
+	}
+
+	modTime := fallbackTime
+	prefix := "undated"
+	if date, err := header.Date(); err == nil {
+		modTime = date
+		prefix = date.UTC().Format("2006-01-02_150405")
+	}
+
+	// Name by Message-ID so the same message keeps its name across exports
+	key := strings.TrimSpace(header.Get("Message-ID"))
+	var sum [32]byte
+	if key != "" {
+		sum = sha256.Sum256([]byte(key))
+	} else {
+		sum = sha256.Sum256(msg)
+	}
+	name := fmt.Sprintf("%s_%x.eml", prefix, sum[:6])
+
+	label := messageLabel(gmailLabels(header.Get("X-Gmail-Labels")))
+	return filepath.Join(folder, z.labelPath(label), name), modTime
+}
+
+// messageLabel picks the label whose folder a message goes into: the first
+// label of the user's own, else the first mailbox such as Inbox. Messages
+// with only flags such as Opened go to the unlabeled folder
+func messageLabel(labels []string) string {
+	mailbox := ""
+	for _, label := range labels {
+		switch {
+		case gmailFlags[label] || strings.HasPrefix(label, "Category "):
+		case gmailMailboxes[label]:
+			if mailbox == "" {
+				mailbox = label
+			}
+		default:
+			return label
+		}
+	}
+	if mailbox != "" {
+		return mailbox
+	}
+	return unlabeledFolder
+}
+
+// gmailLabels parses the comma separated X-Gmail-Labels header, where labels
+// containing commas are quoted and non-ASCII labels may be MIME encoded
+func gmailLabels(value string) []string {
+	if value == "" {
+		return nil
+	}
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(value); err == nil {
+		value = decoded
+	}
+
+	r := csv.NewReader(strings.NewReader(value))
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	fields, err := r.Read()
+	if err != nil {
+		fields = strings.Split(value, ",")
+	}
+
+	var labels []string
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			labels = append(labels, field)
+		}
+	}
+	return labels
+}
+
+// labelPath turns a Gmail label into a relative folder path. Nested labels
+// ("Work/Projects") become nested folders, components that would escape the
+// mbox folder are replaced, and each folder name is sanitised like entry names
+func (z *Extractor) labelPath(label string) string {
+	var parts []string
+	for _, part := range strings.Split(label, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "." || part == ".." {
+			part = "_"
+		}
+		part = z.profile.sanitizeName(strings.ReplaceAll(part, "\\", "_"))
+		parts = append(parts, z.normalization.apply(part))
+	}
+	return filepath.Join(parts...)
+}
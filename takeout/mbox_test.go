@@ -0,0 +1,170 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMbox = "From 1234@xxx Mon Jan 02 10:00:00 +0000 2023\r\n" +
+	"X-Gmail-Labels: Inbox,Important\r\n" +
+	"Message-ID: <one@example.com>\r\n" +
+	"Date: Mon, 02 Jan 2023 10:00:00 +0000\r\n" +
+	"Subject: First\r\n" +
+	"\r\n" +
+	"Hello\r\n" +
+	">From the archive\r\n" +
+	"\r\n" +
+	"From 5678@xxx Tue Jan 03 11:00:00 +0000 2023\r\n" +
+	"X-Gmail-Labels: \"Work/Projects, 2023\",Opened\r\n" +
+	"Message-ID: <two@example.com>\r\n" +
+	"Date: Tue, 03 Jan 2023 11:00:00 +0000\r\n" +
+	"Subject: Second\r\n" +
+	"\r\n" +
+	"World\r\n"
+
+func TestReadMboxMessages(t *testing.T) {
+	var messages []string
+	err := readMboxMessages(strings.NewReader(testMbox), func(msg []byte) error {
+		messages = append(messages, string(msg))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readMboxMessages() error = %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Got %d messages, want 2", len(messages))
+	}
+	if !strings.HasPrefix(messages[0], "X-Gmail-Labels: Inbox") {
+		t.Errorf("First message should start with headers, got %q", messages[0])
+	}
+	if !strings.HasSuffix(messages[0], "Hello\r\nFrom the archive\r\n") {
+		t.Errorf("First message body not unquoted or separator not trimmed: %q", messages[0])
+	}
+	if !strings.HasSuffix(messages[1], "World\r\n") {
+		t.Errorf("Second message truncated: %q", messages[1])
+	}
+}
+
+func TestGmailLabels(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"Inbox,Important", []string{"Inbox", "Important"}},
+		{"\"Work/Projects, 2023\",Opened", []string{"Work/Projects, 2023", "Opened"}},
+		{"=?UTF-8?Q?Kvitton_=C3=A5r?=,Inbox", []string{"Kvitton år", "Inbox"}},
+	}
+	for _, tt := range tests {
+		got := gmailLabels(tt.value)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("gmailLabels(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMessageLabel(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   string
+	}{
+		{nil, unlabeledFolder},
+		{[]string{"Opened", "Category Updates", "Archived"}, unlabeledFolder},
+		{[]string{"Important", "Inbox"}, "Inbox"},
+		{[]string{"Opened", "Inbox", "Receipts", "Work"}, "Receipts"},
+		{[]string{"Starred", "Sent"}, "Sent"},
+	}
+	for _, tt := range tests {
+		if got := messageLabel(tt.labels); got != tt.want {
+			t.Errorf("messageLabel(%q) = %q, want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestLabelPath(t *testing.T) {
+	tests := []struct {
+		profile NameProfile
+		label   string
+		want    string
+	}{
+		{ProfilePOSIX, "Work/Projects, 2023", filepath.Join("Work", "Projects, 2023")},
+		{ProfilePOSIX, "../Up\\Side", filepath.Join("_", "Up_Side")},
+		{ProfileWindows, "Receipts: 2023?/To do*", filepath.Join("Receipts： 2023？", "To do＊")},
+		{ProfileWindows, "CON", "CON_"},
+		{ProfileExFAT, "Trailing.", "Trailing．"},
+	}
+	for _, tt := range tests {
+		z := New(Options{Profile: tt.profile})
+		if got := z.labelPath(tt.label); got != tt.want {
+			t.Errorf("labelPath(%q) with %s = %q, want %q", tt.label, tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestExtractMbox(t *testing.T) {
+	extractDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "Takeout/Mail/All mail.mbox", content: testMbox},
+	})
+	defer os.Remove(zipPath)
+
+	extractor := New(Options{Destination: extractDir, BasePath: "Takeout", Workers: 1, SplitMbox: true})
+	if _, err := extractor.Unzip(zipPath); err != nil {
+		t.Fatalf("Unzip() error = %v", err)
+	}
+
+	if FileExists(filepath.Join(extractDir, "Mail", "All mail.mbox")) {
+		t.Error("mbox file should not be extracted when splitting")
+	}
+
+	inbox, _ := filepath.Glob(filepath.Join(extractDir, "Mail", "All mail", "Inbox", "2023-01-02_100000_*.eml"))
+	if len(inbox) != 1 {
+		t.Fatalf("Expected 1 message in Inbox, got %v", inbox)
+	}
+	info, err := os.Stat(inbox[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTime := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	if !info.ModTime().Equal(wantTime) {
+		t.Errorf("Message mtime = %v, want %v", info.ModTime(), wantTime)
+	}
+
+	nested, _ := filepath.Glob(filepath.Join(extractDir, "Mail", "All mail", "Work", "Projects, 2023", "*.eml"))
+	if len(nested) != 1 {
+		t.Errorf("Expected 1 message in nested label folder, got %v", nested)
+	}
+
+	// A second run should skip every message
+	rerun := New(Options{Destination: extractDir, BasePath: "Takeout", Workers: 1, SplitMbox: true})
+	if _, err := rerun.Unzip(zipPath); err != nil {
+		t.Fatalf("Unzip() rerun error = %v", err)
+	}
+	for _, log := range rerun.GetLogs() {
+		if log.Status != "Skipped" {
+			t.Errorf("Rerun log for %s = %q, want Skipped", log.DestPath, log.Status)
+		}
+	}
+}
+
+func TestExtractMboxDuplicates(t *testing.T) {
+	extractDir := t.TempDir()
+	duplicated := testMbox + "\r\n" + testMbox[:strings.Index(testMbox, "From 5678")] + "Copy\r\n"
+	zipPath := createTestZip(t, []testFile{{name: "All mail.mbox", content: duplicated}})
+	defer os.Remove(zipPath)
+
+	extractor := New(Options{Destination: extractDir, Workers: 1, SplitMbox: true})
+	if _, err := extractor.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	inbox, _ := filepath.Glob(filepath.Join(extractDir, "All mail", "Inbox", "2023-01-02_100000_*.eml"))
+	if len(inbox) != 2 {
+		t.Fatalf("Messages with the same Date and Message-ID = %v, want both kept", inbox)
+	}
+	if !strings.HasSuffix(inbox[1], "_2.eml") {
+		t.Errorf("Second copy named %s, want a _2 suffix", inbox[1])
+	}
+}
@@ -0,0 +1,193 @@
+package takeout
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// FileMetadata is descriptive metadata for an extracted file, from the JSON
+// sidecar Takeout stores next to it
+type FileMetadata struct {
+	Description string
+	Starred     bool      // Starred in Drive or favorited in Photos
+	Created     time.Time // When the photo was taken or the file was created
+}
+
+// sidecar is the part of a Takeout JSON sidecar that is written as metadata
+type sidecar struct {
+	Description    string      `json:"description"`
+	Favorited      bool        `json:"favorited"`
+	Starred        bool        `json:"starred"`
+	PhotoTakenTime sidecarTime `json:"photoTakenTime"`
+	CreationTime   sidecarTime `json:"creationTime"`
+}
+
+type sidecarTime struct {
+	Timestamp string `json:"timestamp"` // Unix seconds
+}
+
+func (t sidecarTime) time() time.Time {
+	seconds, err := strconv.ParseInt(t.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// readSidecar parses the metadata of a JSON sidecar entry
+func readSidecar(f *zip.File) (FileMetadata, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return FileMetadata{}, err
+	}
+	defer rc.Close()
+	var s sidecar
+	if err := json.NewDecoder(rc).Decode(&s); err != nil {
+		return FileMetadata{}, fmt.Errorf("reading %s: %w", f.Name, err)
+	}
+	meta := FileMetadata{
+		Description: strings.TrimSpace(s.Description),
+		Starred:     s.Starred || s.Favorited,
+		Created:     s.PhotoTakenTime.time(),
+	}
+	if meta.Created.IsZero() {
+		meta.Created = s.CreationTime.time()
+	}
+	return meta, nil
+}
+
+// sidecarIndex maps the names of entries to the JSON sidecars describing them.
+// Takeout names sidecars NAME.json or NAME.supplemental-metadata.json, with the
+// suffix cut short when the name gets too long
+func sidecarIndex(files []*zip.File) map[string]*zip.File {
+	index := map[string]*zip.File{}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name, ".json") || f.FileInfo().IsDir() {
+			continue
+		}
+		stem := strings.TrimSuffix(f.Name, ".json")
+		if i := strings.LastIndex(stem, ".s"); i > 0 && i > strings.LastIndex(stem, "/") &&
+			strings.HasPrefix(".supplemental-metadata", stem[i:]) {
+			stem = stem[:i]
+		}
+		if path.Ext(stem) != "" {
+			index[stem] = f
+		}
+	}
+	return index
+}
+
+// sidecars indexes the sidecars of several archives together, as Takeout
+// often puts a file and its sidecar in different parts. It returns nil when
+// metadata is not written
+func (z *Extractor) sidecars(readers []*zip.ReadCloser) map[string]*zip.File {
+	if !z.metadata {
+		return nil
+	}
+	var files []*zip.File
+	for _, r := range readers {
+		files = append(files, r.File...)
+	}
+	return sidecarIndex(files)
+}
+
+// applyMetadata writes the metadata of an extracted file from its sidecar,
+// if it has one. Filesystems and platforms without extended attributes are
+// skipped silently
+func (z *Extractor) applyMetadata(job entryJob) error {
+	var meta FileMetadata
+	if job.sidecar != nil {
+		var err error
+		if meta, err = readSidecar(job.sidecar); err != nil {
+			return err
+		}
+	}
+	err := writeMetadata(job.destPath, meta)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// withMetadata applies metadata when enabled and adds any failure to the log
+// reason of the file, which is extracted either way
+func (z *Extractor) withMetadata(job entryJob, reason string) string {
+	if !z.metadata {
+		return reason
+	}
+	err := z.applyMetadata(job)
+	switch {
+	case err == nil:
+		return reason
+	case reason == "":
+		return fmt.Sprintf("Metadata not written: %v", err)
+	}
+	return fmt.Sprintf("%s, metadata not written: %v", reason, err)
+}
+
+// binaryPlist encodes a string or a list of strings as a binary property
+// list, the format macOS stores Finder comments and tags in
+func binaryPlist(value any) []byte {
+	var objects [][]byte
+	switch v := value.(type) {
+	case string:
+		objects = append(objects, plistString(v))
+	case []string:
+		array := plistLength(0xA0, len(v))
+		for i, s := range v {
+			array = binary.BigEndian.AppendUint16(array, uint16(i+1))
+			objects = append(objects, plistString(s))
+		}
+		objects = append([][]byte{array}, objects...)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("bplist00")
+	offsets := make([]uint64, len(objects))
+	for i, object := range objects {
+		offsets[i] = uint64(buf.Len())
+		buf.Write(object)
+	}
+	tableOffset := uint64(buf.Len())
+	for _, offset := range offsets {
+		binary.Write(&buf, binary.BigEndian, offset)
+	}
+	// Trailer: offset and reference sizes, object count, top object and
+	// offset table position
+	buf.Write(make([]byte, 6))
+	buf.Write([]byte{8, 2})
+	binary.Write(&buf, binary.BigEndian, uint64(len(objects)))
+	binary.Write(&buf, binary.BigEndian, uint64(0))
+	binary.Write(&buf, binary.BigEndian, tableOffset)
+	return buf.Bytes()
+}
+
+func plistString(s string) []byte {
+	if isASCII(s) {
+		return append(plistLength(0x50, len(s)), s...)
+	}
+	units := utf16.Encode([]rune(s))
+	out := plistLength(0x60, len(units))
+	for _, u := range units {
+		out = binary.BigEndian.AppendUint16(out, u)
+	}
+	return out
+}
+
+// plistLength writes an object marker with its length, which follows as an
+// integer object when it doesn't fit in the marker
+func plistLength(marker byte, n int) []byte {
+	if n < 15 {
+		return []byte{marker | byte(n)}
+	}
+	return binary.BigEndian.AppendUint32([]byte{marker | 0x0F, 0x12}, uint32(n))
+}
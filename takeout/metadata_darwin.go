@@ -0,0 +1,44 @@
+package takeout
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// writeMetadata stores metadata where Finder and Spotlight look for it: the
+// comment and tags attributes and the creation time. Extracted files are also
+// cleared of any quarantine attribute, so they open without a warning
+func writeMetadata(path string, meta FileMetadata) error {
+	if err := unix.Removexattr(path, "com.apple.quarantine"); err != nil && !errors.Is(err, unix.ENOATTR) {
+		return fmt.Errorf("removing quarantine: %w", err)
+	}
+	if meta.Description != "" {
+		if err := setXattr(path, "com.apple.metadata:kMDItemComment", binaryPlist(meta.Description)); err != nil {
+			return err
+		}
+	}
+	if meta.Starred {
+		if err := setXattr(path, "com.apple.metadata:_kMDItemUserTags", binaryPlist([]string{"Starred"})); err != nil {
+			return err
+		}
+	}
+	if !meta.Created.IsZero() {
+		if err := setCreationTime(path, meta.Created); err != nil {
+			return fmt.Errorf("setting creation time: %w", err)
+		}
+	}
+	return nil
+}
+
+func setXattr(path, name string, value []byte) error {
+	err := unix.Setxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		return errors.ErrUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", name, err)
+	}
+	return nil
+}
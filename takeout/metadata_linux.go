@@ -0,0 +1,35 @@
+package takeout
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// writeMetadata stores metadata in the user.xdg.* extended attributes that
+// desktop file managers show. Linux has no way to set the creation time
+func writeMetadata(path string, meta FileMetadata) error {
+	if meta.Description != "" {
+		if err := setXattr(path, "user.xdg.comment", []byte(meta.Description)); err != nil {
+			return err
+		}
+	}
+	if meta.Starred {
+		if err := setXattr(path, "user.xdg.tags", []byte("Starred")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setXattr(path, name string, value []byte) error {
+	err := unix.Setxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		return errors.ErrUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", name, err)
+	}
+	return nil
+}
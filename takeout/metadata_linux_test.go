@@ -0,0 +1,78 @@
+package takeout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// skipWithoutXattrs skips the test when dir's filesystem has no user extended
+// attributes
+func skipWithoutXattrs(t *testing.T, dir string) {
+	t.Helper()
+	probe := filepath.Join(dir, "probe")
+	os.WriteFile(probe, nil, 0644)
+	defer os.Remove(probe)
+	if err := unix.Setxattr(probe, "user.probe", []byte("1"), 0); errors.Is(err, unix.ENOTSUP) {
+		t.Skip("filesystem has no user extended attributes")
+	}
+}
+
+func TestUnzipWritesXattrs(t *testing.T) {
+	destDir := t.TempDir()
+	skipWithoutXattrs(t, destDir)
+
+	zipPath := createTestZip(t, []testFile{
+		{name: "Photos/IMG_0001.jpg", content: "jpeg", modTime: time.Now().Add(-time.Hour)},
+		{name: "Photos/IMG_0001.jpg.supplemental-metadata.json", content: `{"description": "Beach", "favorited": true, "photoTakenTime": {"timestamp": "1600000000"}}`},
+		{name: "Photos/IMG_0002.jpg", content: "jpeg"},
+	})
+	defer os.Remove(zipPath)
+
+	if _, err := New(Options{Destination: destDir, Metadata: true, Workers: 2}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	photo := filepath.Join(destDir, "Photos", "IMG_0001.jpg")
+	if n, err := unix.Getxattr(photo, "user.xdg.comment", buf); err != nil || string(buf[:n]) != "Beach" {
+		t.Errorf("user.xdg.comment = %q, %v", buf[:max(n, 0)], err)
+	}
+	if n, err := unix.Getxattr(photo, "user.xdg.tags", buf); err != nil || string(buf[:n]) != "Starred" {
+		t.Errorf("user.xdg.tags = %q, %v", buf[:max(n, 0)], err)
+	}
+	if _, err := unix.Getxattr(filepath.Join(destDir, "Photos", "IMG_0002.jpg"), "user.xdg.comment", buf); err == nil {
+		t.Error("file without a sidecar got a comment")
+	}
+}
+
+func TestSidecarInOtherArchive(t *testing.T) {
+	photos := createTestZip(t, []testFile{{name: "Photos/IMG_0001.jpg", content: "jpeg"}})
+	sidecars := createTestZip(t, []testFile{{name: "Photos/IMG_0001.jpg.json", content: `{"description": "Beach"}`}})
+	defer os.Remove(photos)
+	defer os.Remove(sidecars)
+
+	unzipDir, applyDir := t.TempDir(), t.TempDir()
+	skipWithoutXattrs(t, unzipDir)
+	if _, err := New(Options{Destination: unzipDir, Metadata: true}).UnzipAll([]string{photos, sidecars}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := New(Options{Destination: applyDir}).Plan([]string{photos, sidecars})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{Metadata: true}).Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	for _, dir := range []string{unzipDir, applyDir} {
+		photo := filepath.Join(dir, "Photos", "IMG_0001.jpg")
+		if n, err := unix.Getxattr(photo, "user.xdg.comment", buf); err != nil || string(buf[:n]) != "Beach" {
+			t.Errorf("user.xdg.comment of %s = %q, %v", photo, buf[:max(n, 0)], err)
+		}
+	}
+}
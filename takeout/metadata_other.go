@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package takeout
+
+import "errors"
+
+// writeMetadata is not supported on this platform, so metadata is skipped
+func writeMetadata(path string, meta FileMetadata) error {
+	return errors.ErrUnsupported
+}
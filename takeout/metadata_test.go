@@ -0,0 +1,43 @@
+package takeout
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func TestSidecarIndex(t *testing.T) {
+	var files []*zip.File
+	for _, name := range []string{
+		"Photos/IMG_0001.jpg.json",
+		"Photos/IMG_0002.jpg.supplemental-metadata.json",
+		"Photos/A_very_long_photo_name_from_a_camera.jpg.supplemental-met.json",
+		"Photos/metadata.json",
+		"Drive/settings.json",
+	} {
+		files = append(files, &zip.File{FileHeader: zip.FileHeader{Name: name}})
+	}
+	index := sidecarIndex(files)
+	for entry, sidecar := range map[string]string{
+		"Photos/IMG_0001.jpg":                             "Photos/IMG_0001.jpg.json",
+		"Photos/IMG_0002.jpg":                             "Photos/IMG_0002.jpg.supplemental-metadata.json",
+		"Photos/A_very_long_photo_name_from_a_camera.jpg": "Photos/A_very_long_photo_name_from_a_camera.jpg.supplemental-met.json",
+	} {
+		if f := index[entry]; f == nil || f.Name != sidecar {
+			t.Errorf("sidecar of %s = %v, want %s", entry, f, sidecar)
+		}
+	}
+	if len(index) != 3 {
+		t.Errorf("index has %d sidecars, want 3", len(index))
+	}
+}
+
+func TestBinaryPlist(t *testing.T) {
+	data := binaryPlist([]string{"Starred"})
+	if !bytes.HasPrefix(data, []byte("bplist00\xa1\x00\x01\x57Starred")) {
+		t.Errorf("unexpected encoding %q", data)
+	}
+	if len(data) != 8+3+8+2*8+32 {
+		t.Errorf("encoding is %d bytes, want %d", len(data), 8+3+8+2*8+32)
+	}
+}
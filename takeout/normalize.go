@@ -0,0 +1,95 @@
+package takeout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization selects the Unicode normalization form of destination names
+type Normalization string
+
+const (
+	// NormalizeNone keeps names as they are in the archive
+	NormalizeNone Normalization = "none"
+	// NormalizeNFC composes accented characters, as most exports and Linux do
+	NormalizeNFC Normalization = "nfc"
+	// NormalizeNFD decomposes them, as older macOS filesystems do
+	NormalizeNFD Normalization = "nfd"
+)
+
+// ParseNormalization returns the normalization with the name, where empty
+// means none
+func ParseNormalization(name string) (Normalization, error) {
+	switch n := Normalization(strings.ToLower(name)); n {
+	case "":
+		return NormalizeNone, nil
+	case NormalizeNone, NormalizeNFC, NormalizeNFD:
+		return n, nil
+	}
+	return "", fmt.Errorf("unknown normalization %q, want none, nfc or nfd", name)
+}
+
+func (n Normalization) apply(name string) string {
+	switch n {
+	case NormalizeNFC:
+		return norm.NFC.String(name)
+	case NormalizeNFD:
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// existingVariant returns the path of an existing file or folder that only
+// differs from path in Unicode normalization, such as Café.pdf stored
+// decomposed by a sync client, or path itself when there is none
+func (z *Extractor) existingVariant(path string) string {
+	if isASCII(path) {
+		return path
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return path
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	parent = z.existingVariant(parent)
+	base := filepath.Base(path)
+	if actual, ok := z.folderNames(parent)[norm.NFC.String(base)]; ok {
+		return filepath.Join(parent, actual)
+	}
+	return filepath.Join(parent, base)
+}
+
+// folderNames indexes the names in a folder by their NFC form. Listings are
+// cached, since files written later are found by their exact name
+func (z *Extractor) folderNames(dir string) map[string]string {
+	z.folderMutex.Lock()
+	defer z.folderMutex.Unlock()
+	if names, ok := z.folders[dir]; ok {
+		return names
+	}
+	names := map[string]string{}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !isASCII(entry.Name()) {
+			names[norm.NFC.String(entry.Name())] = entry.Name()
+		}
+	}
+	z.folders[dir] = names
+	return names
+}
@@ -0,0 +1,70 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestUnzipFindsOtherNormalization(t *testing.T) {
+	destDir := t.TempDir()
+	nfc := norm.NFC.String("Café")
+	nfd := norm.NFD.String("Café")
+
+	// A sync client stored the folder and file decomposed
+	if err := os.MkdirAll(filepath.Join(destDir, nfd), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, nfd, nfd+".pdf"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := createTestZip(t, []testFile{
+		{name: nfc + "/" + nfc + ".pdf", content: "new"},
+	})
+	defer os.Remove(zipPath)
+
+	result, err := New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 1 {
+		t.Errorf("Extracted %d files, want 1", result.Extracted)
+	}
+	entries, _ := os.ReadDir(destDir)
+	if len(entries) != 1 || entries[0].Name() != nfd {
+		t.Fatalf("Destination holds %v, want only the existing %q folder", entries, nfd)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destDir, nfd, nfd+".pdf")); string(data) != "new" {
+		t.Errorf("Existing file content = %q, want it replaced in place", data)
+	}
+}
+
+func TestUnzipNormalizeNFD(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: norm.NFC.String("Résumé.txt"), content: "cv"},
+	})
+	defer os.Remove(zipPath)
+
+	result, err := New(Options{Destination: destDir, Normalize: NormalizeNFD, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed != 1 {
+		t.Errorf("Renamed %d files, want 1", result.Renamed)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, norm.NFD.String("Résumé.txt"))); err != nil {
+		t.Errorf("NFD name not written: %v", err)
+	}
+
+	// A second run finds the file it wrote and leaves it alone
+	result, err = New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Skipped != 1 || result.Extracted != 0 {
+		t.Errorf("Rerun skipped %d and extracted %d, want 1 and 0", result.Skipped, result.Extracted)
+	}
+}
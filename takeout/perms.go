@@ -0,0 +1,116 @@
+package takeout
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PermPolicy selects the permissions of extracted files and folders
+type PermPolicy string
+
+const (
+	// PermsPreserve uses the permission bits stored in the archive, limited by
+	// the umask. Entries without any, as in many Takeout zips, get the umask
+	// defaults
+	PermsPreserve PermPolicy = "preserve"
+	// PermsNormalize sets every file and new folder to Options.FileMode and
+	// Options.DirMode, regardless of the archive and umask
+	PermsNormalize PermPolicy = "normalize"
+	// PermsUmask ignores the archive and creates files and folders with the
+	// umask defaults, usually 0644 and 0755
+	PermsUmask PermPolicy = "umask"
+)
+
+// Default modes of PermsNormalize
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// ParsePermPolicy returns the policy with the name, where empty means preserve
+func ParsePermPolicy(name string) (PermPolicy, error) {
+	switch p := PermPolicy(strings.ToLower(name)); p {
+	case "":
+		return PermsPreserve, nil
+	case PermsPreserve, PermsNormalize, PermsUmask:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown permission policy %q, want preserve, normalize or umask", name)
+}
+
+// Owner is the user and group extracted files are given. An ID of -1 leaves
+// it unchanged
+type Owner struct {
+	UID int
+	GID int
+}
+
+// filePerm returns the permission bits a file is created with
+func (z *Extractor) filePerm(f *zip.File) os.FileMode {
+	switch z.perms {
+	case PermsNormalize:
+		return z.fileMode
+	case PermsUmask:
+		return 0666
+	}
+	if perm := f.Mode().Perm(); perm != 0 {
+		return perm
+	}
+	return 0666
+}
+
+// mkdirAll creates a folder and any missing parents, applying the folder mode
+// and owner to the folders it creates. Existing folders are left alone
+func (z *Extractor) mkdirAll(dir string) error {
+	if z.perms != PermsNormalize && z.owner == nil {
+		return os.MkdirAll(dir, os.ModePerm)
+	}
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a folder", dir)
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := z.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+
+	mode := os.ModePerm
+	if z.perms == PermsNormalize {
+		mode = z.dirMode
+	}
+	if err := os.Mkdir(dir, mode); err != nil {
+		if os.IsExist(err) {
+			// Another worker created it
+			return nil
+		}
+		return err
+	}
+	return z.applyOwnership(dir, mode)
+}
+
+// applyOwnership sets the mode of a file or folder the extractor wrote when
+// permissions are normalized, and its owner when one is set. Symlinks only
+// get their owner changed
+func (z *Extractor) applyOwnership(path string, mode os.FileMode) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if z.perms == PermsNormalize && info.Mode()&os.ModeSymlink == 0 && info.Mode().Perm() != mode {
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("setting mode: %w", err)
+		}
+	}
+	if z.owner != nil {
+		if err := os.Lchown(path, z.owner.UID, z.owner.GID); err != nil {
+			return fmt.Errorf("setting owner: %w", err)
+		}
+	}
+	return nil
+}
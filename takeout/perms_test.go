@@ -0,0 +1,57 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnzipPermPolicies(t *testing.T) {
+	zipPath := createModeZip(t, map[string]os.FileMode{
+		"Drive/no-mode.txt":  0,
+		"Drive/writable.txt": 0666,
+		"Drive/script.sh":    0755,
+	}, nil)
+
+	perm := func(destDir, name string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(destDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	destDir := t.TempDir()
+	if _, err := New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := perm(destDir, "Drive/no-mode.txt"); got&0600 != 0600 {
+		t.Errorf("preserve gave an entry without mode bits %v, want it readable and writable", got)
+	}
+	if got := perm(destDir, "Drive/script.sh"); got&0100 == 0 {
+		t.Errorf("preserve lost the executable bit: %v", got)
+	}
+
+	destDir = t.TempDir()
+	z := New(Options{Destination: destDir, Perms: PermsNormalize, FileMode: 0640, DirMode: 0750, Owner: &Owner{UID: os.Getuid(), GID: os.Getgid()}, Workers: 1})
+	if _, err := z.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Drive/no-mode.txt", "Drive/writable.txt", "Drive/script.sh"} {
+		if got := perm(destDir, name); got != 0640 {
+			t.Errorf("normalize gave %s mode %v, want 0640", name, got)
+		}
+	}
+	if got := perm(destDir, "Drive"); got != 0750 {
+		t.Errorf("normalize gave the new folder mode %v, want 0750", got)
+	}
+
+	destDir = t.TempDir()
+	if _, err := New(Options{Destination: destDir, Perms: PermsUmask, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := perm(destDir, "Drive/script.sh"); got&0111 != 0 {
+		t.Errorf("umask kept the executable bit: %v", got)
+	}
+}
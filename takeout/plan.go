@@ -0,0 +1,351 @@
+package takeout
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PlanVersion is the version of the plan file format
+const PlanVersion = 1
+
+// ErrStalePlan is returned by Apply when the archives or the destination
+// changed after the plan was made
+var ErrStalePlan = errors.New("plan is out of date")
+
+// Action is what a plan does with an archive entry
+type Action string
+
+const (
+	ActionExtract  Action = "extract"  // Not at the destination yet
+	ActionReplace  Action = "replace"  // At the destination but different
+	ActionSkip     Action = "skip"     // Already extracted, or provided by a later archive
+	ActionConflict Action = "conflict" // Needs attention and is left alone
+)
+
+// ArchiveFingerprint identifies the exact archive a plan was made from
+type ArchiveFingerprint struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Entries int       `json:"entries"`
+	Digest  string    `json:"digest"` // SHA-256 of the names, sizes and CRCs of all entries
+}
+
+// DestState is what was at a destination path when the plan was made
+type DestState struct {
+	Exists  bool      `json:"exists"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// PlanEntry is the action planned for one archive entry
+type PlanEntry struct {
+	Archive     string    `json:"archive"`
+	Entry       string    `json:"entry"` // Path within the zip
+	Destination string    `json:"destination"`
+	Action      Action    `json:"action"`
+	Reason      string    `json:"reason,omitempty"`
+	Size        int64     `json:"size"`
+	CRC32       uint32    `json:"crc32"`
+	Existing    DestState `json:"existing"`
+}
+
+// Plan lists what extracting a set of archives would do to each entry, so it
+// can be reviewed and later applied exactly
+type Plan struct {
+	Version       int                  `json:"version"`
+	Created       time.Time            `json:"created"`
+	SplitMbox     bool                 `json:"split_mbox"`
+	SplitItems    bool                 `json:"split_items"`
+	Charset       Charset              `json:"charset,omitempty"`
+	NoSymlinks    bool                 `json:"no_symlinks,omitempty"`
+	Timezone      string               `json:"timezone,omitempty"` // Timezone of DOS timestamps, empty to detect it
+	Compare       string               `json:"compare,omitempty"`  // Checks of the comparison strategy, default when empty
+	HashThreshold int64                `json:"hash_threshold,omitempty"`
+	Destinations  []string             `json:"destinations,omitempty"` // Folders entries are extracted into
+	Archives      []ArchiveFingerprint `json:"archives"`
+	Entries       []PlanEntry          `json:"entries"`
+}
+
+// Count returns the number of entries with the action
+func (p *Plan) Count(action Action) int {
+	var n int
+	for _, e := range p.Entries {
+		if e.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// WritePlan saves a plan as indented JSON
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadPlan loads a plan saved by WritePlan
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan %s: %w", path, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("plan %s has version %d, want %d", path, plan.Version, PlanVersion)
+	}
+	return &plan, nil
+}
+
+// fingerprintArchive identifies an open archive by its size and a digest of
+// its central directory, which is cheap even for very large archives
+func fingerprintArchive(path string, r *zip.ReadCloser) (ArchiveFingerprint, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ArchiveFingerprint{}, err
+	}
+	h := sha256.New()
+	for _, f := range r.File {
+		fmt.Fprintf(h, "%s\x00%d\x00%08x\x00%d\n", f.Name, f.UncompressedSize64, f.CRC32, f.Modified.Unix())
+	}
+	return ArchiveFingerprint{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Entries: len(r.File),
+		Digest:  hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// destState records what is at a destination path
+func destState(path string) DestState {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return DestState{}
+	}
+	return DestState{Exists: true, IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+}
+
+func (s DestState) equal(other DestState) bool {
+	return s.Exists == other.Exists && s.IsDir == other.IsDir && s.Size == other.Size && s.ModTime.Equal(other.ModTime)
+}
+
+// Plan decides what extracting the archives would do to each file entry
+// without writing anything. When several archives contain the same path the
+// last one wins, as in extraction, and the earlier entries are skipped when
+// identical or marked as conflicts when they differ
+func (z *Extractor) Plan(zipFiles []string) (*Plan, error) {
+	plan := &Plan{
+		Version:      PlanVersion,
+		Created:      time.Now(),
+		SplitMbox:    z.splitMbox,
+		SplitItems:   z.splitItems,
+		Charset:      z.charset,
+		NoSymlinks:   z.noSymlinks,
+		Timezone:     timezoneName(z.timezone),
+		Destinations: z.destinationRoots(),
+	}
+	if s, ok := z.comparator.(Strategy); ok {
+		plan.Compare, plan.HashThreshold = s.String(), s.HashThreshold
+	}
+	winners := map[string]int{} // Destination path -> index of the entry that ends up there
+
+	for _, zipFile := range zipFiles {
+		archive, err := filepath.Abs(zipFile)
+		if err != nil {
+			return nil, err
+		}
+		r, err := z.OpenArchive(archive)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", zipFile, err)
+		}
+		fingerprint, err := fingerprintArchive(archive, r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		plan.Archives = append(plan.Archives, fingerprint)
+
+		for _, f := range r.File {
+			destPath, include := z.shouldIncludeFile(f.Name)
+			if !include || f.FileInfo().IsDir() {
+				continue
+			}
+			if abs, err := filepath.Abs(destPath); err == nil {
+				destPath = abs
+			}
+			entry := PlanEntry{
+				Archive:     archive,
+				Entry:       f.Name,
+				Destination: destPath,
+				Size:        int64(f.UncompressedSize64),
+				CRC32:       f.CRC32,
+				Existing:    destState(destPath),
+			}
+			entry.Action, entry.Reason = z.planAction(f, destPath, entry.Existing)
+
+			if i, ok := winners[destPath]; ok {
+				prev := &plan.Entries[i]
+				if prev.CRC32 == entry.CRC32 && prev.Size == entry.Size {
+					prev.Action, prev.Reason = ActionSkip, "same file in "+archive
+				} else {
+					prev.Action, prev.Reason = ActionConflict, "a different version in "+archive+" is extracted instead"
+				}
+			}
+			winners[destPath] = len(plan.Entries)
+			plan.Entries = append(plan.Entries, entry)
+		}
+		r.Close()
+	}
+	return plan, nil
+}
+
+// planAction decides what to do with an entry given what is at its destination
+func (z *Extractor) planAction(f *zip.File, destPath string, existing DestState) (Action, string) {
+	switch {
+	case specialKind(f) != "":
+		return ActionSkip, "refusing to create a " + specialKind(f)
+	case isSymlinkEntry(f) && z.noSymlinks:
+		return ActionSkip, "symlinks are disabled"
+	case existing.IsDir:
+		return ActionConflict, "destination is a directory"
+	case z.splitMbox && isMboxEntry(f.Name):
+		// Messages are compared one by one while splitting
+		return ActionExtract, "split into per-message files"
+	case !existing.Exists:
+		return ActionExtract, "not at the destination"
+	}
+	if equal, reason := z.IsFileEqual(f, destPath); !equal {
+		return ActionReplace, reason
+	}
+	return ActionSkip, "already extracted"
+}
+
+// Comparator returns the comparison strategy the plan was made with
+func (p *Plan) Comparator() (Comparator, error) {
+	if p.Compare == "" {
+		return DefaultStrategy, nil
+	}
+	return ParseStrategy(p.Compare, p.HashThreshold)
+}
+
+// mappings returns a mapping for each folder the plan extracts into. Their
+// sources don't matter, as the plan already has the destination of every
+// entry
+func (p *Plan) mappings() []Mapping {
+	var mappings []Mapping
+	for _, dest := range p.Destinations {
+		mappings = append(mappings, Mapping{Destination: dest})
+	}
+	return cleanMappings(mappings)
+}
+
+// Changes lists how the archives and destination differ from when the plan
+// was made. An empty list means the plan can be applied as it is
+func (p *Plan) Changes() []string {
+	var changes []string
+	for _, want := range p.Archives {
+		r, _, err := openArchive(want.Path, p.Charset)
+		if err != nil {
+			changes = append(changes, fmt.Sprintf("%s: %v", want.Path, err))
+			continue
+		}
+		zone, err := ParseTimezone(p.Timezone)
+		if err != nil {
+			r.Close()
+			changes = append(changes, err.Error())
+			continue
+		}
+		fixDOSTimes(r.File, zone)
+		got, err := fingerprintArchive(want.Path, r)
+		r.Close()
+		switch {
+		case err != nil:
+			changes = append(changes, fmt.Sprintf("%s: %v", want.Path, err))
+		case got.Size != want.Size || got.Digest != want.Digest:
+			changes = append(changes, fmt.Sprintf("%s: archive contents changed", want.Path))
+		}
+	}
+	for _, e := range p.Entries {
+		if !destState(e.Destination).equal(e.Existing) {
+			changes = append(changes, fmt.Sprintf("%s: destination changed", e.Destination))
+		}
+	}
+	return changes
+}
+
+// Apply executes the extract and replace actions of a plan and returns a
+// result per archive. It refuses to write anything when the archives or any
+// planned destination changed since the plan was made
+func (z *Extractor) Apply(plan *Plan) ([]*Result, error) {
+	if changes := plan.Changes(); len(changes) > 0 {
+		more := ""
+		if len(changes) > 1 {
+			more = fmt.Sprintf(" and %d more", len(changes)-1)
+		}
+		return nil, fmt.Errorf("%w: %s%s", ErrStalePlan, changes[0], more)
+	}
+
+	planned := map[string]map[string]string{} // Archive -> entry -> destination
+	for _, e := range plan.Entries {
+		if e.Action != ActionExtract && e.Action != ActionReplace {
+			continue
+		}
+		if planned[e.Archive] == nil {
+			planned[e.Archive] = map[string]string{}
+		}
+		planned[e.Archive][e.Entry] = e.Destination
+	}
+
+	if mappings := plan.mappings(); len(mappings) > 0 {
+		// Entries go where the plan says, so symlinks and folder times are
+		// checked against the folders the plan was made for
+		z.mappings = mappings
+	}
+
+	var readers []*zip.ReadCloser
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+	for _, archive := range plan.Archives {
+		r, err := z.OpenArchive(archive.Path)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", archive.Path, err)
+		}
+		readers = append(readers, r)
+	}
+
+	sidecars := z.sidecars(readers)
+	var archives []archiveJobs
+	var jobs []entryJob
+	for i, archive := range plan.Archives {
+		a := archiveJobs{path: archive.Path}
+		for _, f := range readers[i].File {
+			if destPath, ok := planned[archive.Path][f.Name]; ok {
+				a.jobs = append(a.jobs, entryJob{archive: archive.Path, f: f, destPath: destPath, sidecar: sidecars[f.Name]})
+			}
+		}
+		archives = append(archives, a)
+		jobs = append(jobs, a.jobs...)
+	}
+
+	results := z.runArchives(archives)
+	z.restoreFolderTimes(z.destinationRoots(), nil, jobs)
+	return results, resultsErr(results)
+}
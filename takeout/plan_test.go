@@ -0,0 +1,116 @@
+package takeout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	destDir := t.TempDir()
+	testTime := time.Now().Add(-time.Hour).Round(time.Second)
+	zip1 := createTestZip(t, []testFile{
+		{name: "new.txt", content: "new", modTime: testTime},
+		{name: "same.txt", content: "same", modTime: testTime},
+		{name: "changed.txt", content: "original", modTime: testTime},
+		{name: "dup.txt", content: "old version", modTime: testTime},
+	})
+	zip2 := createTestZip(t, []testFile{
+		{name: "dup.txt", content: "new version", modTime: testTime},
+	})
+	defer os.Remove(zip1)
+	defer os.Remove(zip2)
+
+	write := func(name, content string) {
+		path := filepath.Join(destDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, testTime, testTime)
+	}
+	write("same.txt", "same")
+	write("changed.txt", "modified")
+
+	extractor := New(Options{Destination: destDir, Workers: 2})
+	plan, err := extractor.Plan([]string{zip1, zip2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]Action{}
+	for _, e := range plan.Entries {
+		actions[filepath.Base(e.Archive)+":"+e.Entry] = e.Action
+	}
+	want := map[string]Action{
+		filepath.Base(zip1) + ":new.txt":     ActionExtract,
+		filepath.Base(zip1) + ":same.txt":    ActionSkip,
+		filepath.Base(zip1) + ":changed.txt": ActionReplace,
+		filepath.Base(zip1) + ":dup.txt":     ActionConflict,
+		filepath.Base(zip2) + ":dup.txt":     ActionExtract,
+	}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("Action for %s = %q, want %q", key, actions[key], action)
+		}
+	}
+
+	// Round trip through a plan file
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := WritePlan(planPath, plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err = ReadPlan(planPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A destination change after planning makes the plan stale
+	write("same.txt", "edited")
+	if _, err := extractor.Apply(plan); !errors.Is(err, ErrStalePlan) {
+		t.Fatalf("Apply after a change: err = %v, want ErrStalePlan", err)
+	}
+	if FileExists(filepath.Join(destDir, "new.txt")) {
+		t.Fatal("A stale plan must not write anything")
+	}
+	write("same.txt", "same")
+
+	results, err := extractor.Apply(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Extracted != 2 || results[1].Extracted != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	for name, content := range map[string]string{"new.txt": "new", "changed.txt": "original", "dup.txt": "new version"} {
+		if data, _ := os.ReadFile(filepath.Join(destDir, name)); string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestApplySymlink(t *testing.T) {
+	zipPath := createModeZip(t, map[string]os.FileMode{
+		"Takeout/a.txt": 0644,
+		"Takeout/link":  os.ModeSymlink | 0777,
+	}, map[string]string{
+		"Takeout/a.txt": "hello",
+		"Takeout/link":  "a.txt",
+	})
+	destDir := t.TempDir()
+	plan, err := New(Options{Destination: destDir, BasePath: "Takeout"}).Plan([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Like the apply command, the extractor knows nothing of the destination
+	results, err := New(Options{Workers: 1}).Apply(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Extracted != 2 || results[0].Skipped != 0 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if data, err := os.ReadFile(filepath.Join(destDir, "link")); err != nil || string(data) != "hello" {
+		t.Errorf("Symlink reads %q, %v", data, err)
+	}
+}
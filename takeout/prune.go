@@ -0,0 +1,169 @@
+package takeout
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// trashFolderName is the folder under the destination that pruned files are
+// moved to, in a subfolder per run
+const trashFolderName = ".unzip-takeout-trash"
+
+// PruneOptions controls how files no archive provides are removed
+type PruneOptions struct {
+	Delete     bool     // Delete files instead of moving them to the trash folder
+	MaxPercent float64  // Abort when more than this share of files would be removed
+	Keep       []string // Paths that are never pruned, such as the log file
+}
+
+// PruneResult summarises a prune run
+type PruneResult struct {
+	TotalFiles int    // Files under the destination, excluding the trash folder
+	Pruned     int    // Files removed, or that would be removed in a dry run
+	PrunedSize int64  // Size of the pruned files
+	TrashPath  string // Folder pruned files were moved to, under the first destination
+}
+
+// pruneCandidate is a destination file no archive provides
+type pruneCandidate struct {
+	path string
+	root string // Destination folder the file was found under
+	size int64
+}
+
+// Prune removes files under the destination that none of the archives
+// provide. Every archive in the set must be readable, since a missing archive
+// would otherwise make all of its files look deleted upstream
+func (z *Extractor) Prune(zipFiles []string, opts PruneOptions) (*PruneResult, error) {
+	expected := map[string]bool{}
+	derived := map[string]bool{}
+	for _, zipFile := range zipFiles {
+		r, err := z.OpenArchive(zipFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", zipFile, err)
+		}
+		for _, f := range r.File {
+			// Filters only limit what is extracted, files they leave out are
+			// still provided by the archive
+			destPath, ok := z.EntryPath(f.Name)
+			if !ok || f.FileInfo().IsDir() {
+				continue
+			}
+			expected[destPath] = true
+			if z.splitMbox && isMboxEntry(f.Name) || z.splitItems && isItemsEntry(f.Name) {
+				derived[derivedFolder(destPath)] = true
+			}
+		}
+		r.Close()
+	}
+	for _, keep := range opts.Keep {
+		if abs, err := filepath.Abs(keep); err == nil {
+			expected[abs] = true
+		}
+	}
+
+	result := &PruneResult{}
+	var candidates []pruneCandidate
+	for _, root := range z.destinationRoots() {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		trashRoot := filepath.Join(root, trashFolderName)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path == trashRoot {
+					return filepath.SkipDir
+				}
+				if derived[path] {
+					// Split mbox and contact files are provided by their source entry
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			result.TotalFiles++
+			abs, _ := filepath.Abs(path)
+			if expected[path] || expected[abs] {
+				return nil
+			}
+			candidate := pruneCandidate{path: path, root: root}
+			if info, err := d.Info(); err == nil {
+				candidate.size = info.Size()
+			}
+			candidates = append(candidates, candidate)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanning destination: %w", err)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].path < candidates[j].path })
+
+	if result.TotalFiles > 0 && opts.MaxPercent > 0 {
+		percent := float64(len(candidates)) * 100 / float64(result.TotalFiles)
+		if percent > opts.MaxPercent {
+			return result, fmt.Errorf("refusing to prune %d of %d files (%.1f%%), more than the %.1f%% limit",
+				len(candidates), result.TotalFiles, percent, opts.MaxPercent)
+		}
+	}
+
+	stamp := time.Now().Format("2006-01-02_150405")
+	if !opts.Delete {
+		result.TrashPath = filepath.Join(z.destinationRoots()[0], trashFolderName, stamp)
+	}
+
+	var failed int
+	for _, c := range candidates {
+		path, relPath := c.path, RelativeTo(c.root, c.path)
+		if z.dryRun {
+			z.logExtraction("", relPath, path, c.size, "Would Prune", "Not present in any archive")
+			result.Pruned++
+			result.PrunedSize += c.size
+			continue
+		}
+
+		var err error
+		var reason string
+		if opts.Delete {
+			err = os.Remove(path)
+			reason = "Deleted, not present in any archive"
+		} else {
+			trashPath := filepath.Join(c.root, trashFolderName, stamp, relPath)
+			if err = os.MkdirAll(filepath.Dir(trashPath), os.ModePerm); err == nil {
+				err = os.Rename(path, trashPath)
+			}
+			reason = fmt.Sprintf("Moved to %s, not present in any archive", trashPath)
+		}
+		if err != nil {
+			z.logExtraction("", relPath, path, c.size, "Failed", fmt.Sprintf("prune failed: %v", err))
+			failed++
+			continue
+		}
+		z.logExtraction("", relPath, path, c.size, "Pruned", reason)
+		result.Pruned++
+		result.PrunedSize += c.size
+		removeEmptyParents(filepath.Dir(path), c.root)
+	}
+
+	if failed > 0 {
+		return result, fmt.Errorf("failed to prune %d files", failed)
+	}
+	return result, nil
+}
+
+// removeEmptyParents removes dir and its parents up to (but not including)
+// root for as long as they are empty
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
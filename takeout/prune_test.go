@@ -0,0 +1,120 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupPruneTest(t *testing.T, extra int) (string, string) {
+	t.Helper()
+	destDir := t.TempDir()
+	files := []testFile{{name: "keep.txt", content: "keep"}}
+	for i := 0; i < 9; i++ {
+		files = append(files, testFile{name: filepath.Join("dir", string(rune('a'+i))+".txt"), content: "x"})
+	}
+	zipPath := createTestZip(t, files)
+	t.Cleanup(func() { os.Remove(zipPath) })
+
+	if _, err := New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < extra; i++ {
+		path := filepath.Join(destDir, "old", string(rune('a'+i))+".txt")
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("deleted upstream"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return zipPath, destDir
+}
+
+func TestPruneToTrash(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 1)
+
+	extractor := New(Options{Destination: destDir, Workers: 1})
+	result, err := extractor.Prune([]string{zipPath}, PruneOptions{MaxPercent: 10})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if result.Pruned != 1 || result.TotalFiles != 11 {
+		t.Errorf("Pruned %d of %d, want 1 of 11", result.Pruned, result.TotalFiles)
+	}
+	if FileExists(filepath.Join(destDir, "old", "a.txt")) {
+		t.Error("Pruned file still in destination")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "old")); !os.IsNotExist(err) {
+		t.Error("Empty folder left behind after pruning")
+	}
+	if !FileExists(filepath.Join(result.TrashPath, "old", "a.txt")) {
+		t.Errorf("Pruned file not moved to trash %s", result.TrashPath)
+	}
+	if !strings.HasPrefix(result.TrashPath, filepath.Join(destDir, trashFolderName)) {
+		t.Errorf("Trash path %s not under destination", result.TrashPath)
+	}
+
+	// The trash folder itself is never pruned
+	result, err = extractor.Prune([]string{zipPath}, PruneOptions{Delete: true})
+	if err != nil || result.Pruned != 0 {
+		t.Errorf("Second prune = %+v, %v; want nothing pruned", result, err)
+	}
+}
+
+func TestPruneSafetyLimit(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 3)
+
+	extractor := New(Options{Destination: destDir, Workers: 1})
+	if _, err := extractor.Prune([]string{zipPath}, PruneOptions{Delete: true, MaxPercent: 10}); err == nil {
+		t.Fatal("Expected prune to abort above the limit")
+	}
+	if !FileExists(filepath.Join(destDir, "old", "a.txt")) {
+		t.Error("Files removed even though prune aborted")
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 2)
+
+	extractor := New(Options{Destination: destDir, Workers: 1, DryRun: true})
+	result, err := extractor.Prune([]string{zipPath}, PruneOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pruned != 2 {
+		t.Errorf("Dry run pruned %d, want 2", result.Pruned)
+	}
+	if !FileExists(filepath.Join(destDir, "old", "a.txt")) {
+		t.Error("Dry run removed a file")
+	}
+	for _, log := range extractor.GetLogs() {
+		if log.Status != "Would Prune" {
+			t.Errorf("Log status = %q, want Would Prune", log.Status)
+		}
+	}
+}
+
+func TestPruneMissingArchive(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 1)
+
+	extractor := New(Options{Destination: destDir, Workers: 1})
+	if _, err := extractor.Prune([]string{zipPath, filepath.Join(destDir, "missing.zip")}, PruneOptions{}); err == nil {
+		t.Error("Expected an error when an archive can't be read")
+	}
+}
+
+func TestPruneKeepsFilteredEntries(t *testing.T) {
+	zipPath, destDir := setupPruneTest(t, 1)
+
+	extractor := New(Options{Destination: destDir, Includes: []string{"dir"}, Workers: 1})
+	result, err := extractor.Prune([]string{zipPath}, PruneOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pruned != 1 {
+		t.Errorf("Pruned %d files, want only the one deleted upstream", result.Pruned)
+	}
+	if !FileExists(filepath.Join(destDir, "keep.txt")) {
+		t.Error("file left out by --include was pruned")
+	}
+}
@@ -0,0 +1,158 @@
+package takeout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// NameProfile selects the filename rules of the destination filesystem
+type NameProfile string
+
+const (
+	// ProfilePOSIX only shortens names longer than 255 bytes
+	ProfilePOSIX NameProfile = "posix"
+	// ProfileWindows also replaces characters Windows and SMB shares reject,
+	// trailing dots and spaces and reserved device names such as CON
+	ProfileWindows NameProfile = "windows"
+	// ProfileExFAT replaces the characters exFAT rejects and trailing dots
+	// and spaces
+	ProfileExFAT NameProfile = "exfat"
+)
+
+// ParseProfile returns the profile with the name, where empty means posix
+func ParseProfile(name string) (NameProfile, error) {
+	switch p := NameProfile(strings.ToLower(name)); p {
+	case "":
+		return ProfilePOSIX, nil
+	case ProfilePOSIX, ProfileWindows, ProfileExFAT:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown name profile %q, want posix, windows or exfat", name)
+}
+
+// caseInsensitive reports whether names differing only in case refer to the
+// same file on the profile's filesystems
+func (p NameProfile) caseInsensitive() bool {
+	return p == ProfileWindows || p == ProfileExFAT
+}
+
+// maxNameLength is the longest file name component most filesystems accept,
+// in bytes for posix and UTF-16 code units otherwise
+const maxNameLength = 255
+
+// replacements maps characters Windows and exFAT reject to full-width
+// lookalikes, so names stay readable and distinct
+var replacements = map[rune]rune{
+	'<': '＜', '>': '＞', ':': '：', '"': '＂', '\\': '＼', '|': '｜', '?': '？', '*': '＊',
+}
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizePath rewrites each component of a slash-separated path for the
+// profile. The result only depends on the input, so repeated runs agree
+func (p NameProfile) SanitizePath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = p.sanitizeName(part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (p NameProfile) sanitizeName(name string) string {
+	original := name
+	name = strings.ReplaceAll(name, "\x00", "␀")
+
+	if p == ProfileWindows || p == ProfileExFAT {
+		var b strings.Builder
+		for _, r := range name {
+			switch {
+			case r < 0x20:
+				b.WriteRune(0x2400 + r) // Control pictures, such as ␉ for a tab
+			case replacements[r] != 0:
+				b.WriteRune(replacements[r])
+			default:
+				b.WriteRune(r)
+			}
+		}
+		name = b.String()
+
+		// Windows silently drops trailing dots and spaces
+		trimmed := strings.TrimRight(name, ". ")
+		for _, r := range name[len(trimmed):] {
+			if r == '.' {
+				trimmed += "．"
+			} else {
+				trimmed += "␠"
+			}
+		}
+		name = trimmed
+	}
+
+	if p == ProfileWindows {
+		stem, _, _ := strings.Cut(name, ".")
+		if reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+			name = stem + "_" + name[len(stem):]
+		}
+	}
+
+	if p.nameLength(name) > maxNameLength {
+		name = p.shorten(name, original)
+	}
+	return name
+}
+
+func (p NameProfile) nameLength(name string) int {
+	if p == ProfilePOSIX {
+		return len(name)
+	}
+	n := 0
+	for _, r := range name {
+		if r >= 0x10000 {
+			n += 2 // Surrogate pair
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// shorten cuts a long name to fit, keeping the extension and adding a hash of
+// the original name so that names sharing a long prefix stay distinct
+func (p NameProfile) shorten(name, original string) string {
+	ext := path.Ext(name)
+	if p.nameLength(ext) > 16 {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+	suffix := "~" + shortHash(original) + ext
+
+	limit := maxNameLength - p.nameLength(suffix)
+	for p.nameLength(stem) > limit {
+		_, size := utf8.DecodeLastRuneInString(stem)
+		stem = stem[:len(stem)-size]
+	}
+	return stem + suffix
+}
+
+// shortHash returns a short hex digest used to keep rewritten names unique
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:3])
+}
+
+// withHash inserts a hash of the zip path before the extension of the last
+// path component, to separate entries that collide on the destination
+func withHash(name, zipPath string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "~" + shortHash(zipPath) + ext
+}
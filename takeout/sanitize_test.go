@@ -0,0 +1,96 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := []struct {
+		profile NameProfile
+		name    string
+		want    string
+	}{
+		{ProfilePOSIX, "Drive/a:b?.txt", "Drive/a:b?.txt"},
+		{ProfileWindows, "Drive/a:b?.txt", "Drive/a：b？.txt"},
+		{ProfileWindows, "Notes./draft ", "Notes．/draft␠"},
+		{ProfileWindows, "Drive/con.txt", "Drive/con_.txt"},
+		{ProfileWindows, "Drive/Console.txt", "Drive/Console.txt"},
+		{ProfileExFAT, "Drive/con.txt", "Drive/con.txt"},
+		{ProfileExFAT, "tab\there|", "tab␉here｜"},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.SanitizePath(tt.name); got != tt.want {
+			t.Errorf("%s.SanitizePath(%q) = %q, want %q", tt.profile, tt.name, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("é", 300) + ".jpg"
+	for _, profile := range []NameProfile{ProfilePOSIX, ProfileWindows} {
+		got := profile.SanitizePath(long)
+		if profile.nameLength(got) > maxNameLength || !strings.HasSuffix(got, ".jpg") || !strings.Contains(got, "~") {
+			t.Errorf("%s shortened %d-byte name to %q (%d)", profile, len(long), got, profile.nameLength(got))
+		}
+		if profile.SanitizePath(long) != got {
+			t.Errorf("%s shortening is not deterministic", profile)
+		}
+	}
+}
+
+func TestUnzipWindowsProfile(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "Photos/A.jpg", content: "upper"},
+		{name: "photos/a.jpg", content: "lower"},
+		{name: "Drive/What?.txt", content: "question"},
+	})
+	defer os.Remove(zipPath)
+
+	result, err := New(Options{Destination: destDir, Profile: ProfileWindows, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed != 2 || result.Extracted != 3 {
+		t.Errorf("Result renamed %d, extracted %d; want 2 and 3", result.Renamed, result.Extracted)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destDir, "Drive", "What？.txt")); string(data) != "question" {
+		t.Errorf("Sanitised file content = %q", data)
+	}
+	collided := filepath.Join(destDir, "photos", "a~"+shortHash("photos/a.jpg")+".jpg")
+	if data, _ := os.ReadFile(collided); string(data) != "lower" {
+		t.Errorf("Colliding file not written to %s", collided)
+	}
+	for _, log := range result.Logs {
+		if log.Status == "Renamed" && log.Path == "photos/a.jpg" && !strings.Contains(log.Reason, "Photos/A.jpg") {
+			t.Errorf("Collision log should name the other entry: %q", log.Reason)
+		}
+	}
+}
+
+func TestCaseInsensitiveDestination(t *testing.T) {
+	destDir := t.TempDir()
+	if caseInsensitiveDir(destDir) {
+		t.Skip("temporary folder is case-insensitive already")
+	}
+	if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+		t.Errorf("case probe left %d files behind", len(entries))
+	}
+	zipPath := createTestZip(t, []testFile{
+		{name: "A.txt", content: "upper"},
+		{name: "a.txt", content: "lower"},
+	})
+	defer os.Remove(zipPath)
+
+	// Pretend the destination is on APFS, which ignores case
+	z := New(Options{Destination: destDir, Workers: 1})
+	z.caseFolding[destDir] = true
+	if _, err := z.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	collided := filepath.Join(destDir, "a~"+shortHash("a.txt")+".txt")
+	if data, _ := os.ReadFile(collided); string(data) != "lower" {
+		t.Errorf("Colliding file not written to %s with the posix profile", collided)
+	}
+}
@@ -0,0 +1,214 @@
+package takeout
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// archiveJobs are the file entries of one archive to process
+type archiveJobs struct {
+	path string
+	jobs []entryJob
+}
+
+// scheduledJob is an entry job and the index of the archive it belongs to
+type scheduledJob struct {
+	archive int
+	job     entryJob
+}
+
+// task is one unit of work on the worker pool: the jobs of every archive
+// writing to the same destination path, run one after the other in archive
+// order, so the last archive wins as when extracting them one by one
+type task []scheduledJob
+
+// size is the largest entry of the task, which decides when it is scheduled
+func (t task) size() uint64 {
+	var size uint64
+	for _, s := range t {
+		size = max(size, s.job.f.UncompressedSize64)
+	}
+	return size
+}
+
+// UnzipAll extracts several archives at once, feeding the entries of all of
+// them to one worker pool, largest first, so workers don't sit idle at the
+// end of each archive. It returns a result for each archive, in the order
+// given. The error is non-nil when an archive can't be read, in which case
+// nothing is extracted, or any file failed to extract
+func (z *Extractor) UnzipAll(zipPaths []string) ([]*Result, error) {
+	var readers []*zip.ReadCloser
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	for _, zipPath := range zipPaths {
+		r, err := z.OpenArchive(zipPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip %s: %w", zipPath, err)
+		}
+		readers = append(readers, r)
+	}
+
+	sidecars := z.sidecars(readers)
+	var archives []archiveJobs
+	var dirs []string
+	for i, zipPath := range zipPaths {
+		jobs, archiveDirs := z.archiveJobs(zipPath, readers[i], sidecars)
+		archives = append(archives, archiveJobs{path: zipPath, jobs: jobs})
+		dirs = append(dirs, archiveDirs...)
+	}
+
+	results := z.runArchives(archives)
+	var jobs []entryJob
+	for _, a := range archives {
+		jobs = append(jobs, a.jobs...)
+	}
+	z.restoreFolderTimes(z.destinationRoots(), dirs, jobs)
+	return results, resultsErr(results)
+}
+
+// archiveJobs lists the file entries of an archive to process, with their
+// sidecars from the index, and creates the folders it has entries for, which
+// are also returned
+func (z *Extractor) archiveJobs(zipPath string, r *zip.ReadCloser, sidecars map[string]*zip.File) ([]entryJob, []string) {
+	var jobs []entryJob
+	var dirs []string
+	for _, f := range r.File {
+		destPath, include := z.shouldIncludeFile(f.Name)
+		if !include {
+			continue
+		}
+
+		if f.FileInfo().IsDir() {
+			if !z.dryRun {
+				z.mkdirAll(destPath)
+				z.noteFolderTime(destPath, f.Modified, true)
+				dirs = append(dirs, destPath)
+			}
+			continue
+		}
+		if decoding, ok := z.nameDecoding(f.Name); ok {
+			z.logExtraction(zipPath, f.Name, destPath, int64(f.UncompressedSize64), "Decoded", decoding.Reason())
+		}
+		if reason, renamed := z.renameReason(f.Name); renamed {
+			z.logExtraction(zipPath, f.Name, destPath, int64(f.UncompressedSize64), "Renamed", reason)
+		}
+		jobs = append(jobs, entryJob{archive: zipPath, f: f, destPath: destPath, sidecar: sidecars[f.Name]})
+	}
+	return jobs, dirs
+}
+
+// runArchives processes the file entries of several archives on one worker
+// pool and returns a result for each. An archive is finished, and reported to
+// the events, as soon as its last entry is done
+func (z *Extractor) runArchives(archives []archiveJobs) []*Result {
+	var tasks []task
+	byDest := map[string]int{} // Destination path -> index of its task
+	for i, a := range archives {
+		z.events.ArchiveStarted(a.path, len(a.jobs))
+		for _, job := range a.jobs {
+			if t, ok := byDest[job.destPath]; ok {
+				tasks[t] = append(tasks[t], scheduledJob{i, job})
+				continue
+			}
+			byDest[job.destPath] = len(tasks)
+			tasks = append(tasks, task{{i, job}})
+		}
+	}
+
+	pool := newLimiter(z.workers)
+	switch {
+	case z.dryRun:
+		// Keep dry run logs in archive order
+		pool = newLimiter(1)
+	case z.tuner != nil:
+		pool = z.tuner.limiter
+	}
+	if !z.dryRun {
+		// Start the largest files first, so the run doesn't end waiting on one
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].size() > tasks[j].size() })
+	}
+
+	results := make([]*Result, len(archives))
+	remaining := make([]int, len(archives))
+	archiveErrors := make([][]error, len(archives))
+	var mutex sync.Mutex
+	finish := func(i int) {
+		errs := archiveErrors[i]
+		if z.stopped.Load() {
+			errs = append([]error{ErrInsufficientSpace}, errs...)
+		}
+		results[i] = z.archiveResult(archives[i].path, len(archives[i].jobs), errs)
+		z.events.ArchiveFinished(results[i])
+	}
+	for i, a := range archives {
+		remaining[i] = len(a.jobs)
+		if remaining[i] == 0 {
+			finish(i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		pool.acquire()
+		if z.stopped.Load() {
+			// The destination is low on space, leave the remaining entries
+			pool.release()
+			break
+		}
+		wg.Add(1)
+
+		go func(t task) {
+			defer wg.Done()
+			defer pool.release()
+			for _, s := range t {
+				err := z.processEntry(s.job)
+				z.events.FileDone(s.job.archive, s.job.f.Name)
+
+				mutex.Lock()
+				if err != nil {
+					archiveErrors[s.archive] = append(archiveErrors[s.archive], fmt.Errorf("error extracting %s: %w", s.job.destPath, err))
+				}
+				remaining[s.archive]--
+				done := remaining[s.archive] == 0
+				mutex.Unlock()
+				if done {
+					finish(s.archive)
+				}
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	// Archives with entries left after running low on space
+	for i, result := range results {
+		if result == nil {
+			finish(i)
+		}
+	}
+	return results
+}
+
+// resultsErr combines the errors of several archive results
+func resultsErr(results []*Result) error {
+	var errs []string
+	for _, result := range results {
+		if err := result.Err(); err != nil {
+			if errors.Is(err, ErrInsufficientSpace) {
+				return err
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", result.Archive, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
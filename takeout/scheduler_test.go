@@ -0,0 +1,98 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type finishedEvents struct {
+	NopEvents
+	mu       sync.Mutex
+	started  []string
+	finished []string
+}
+
+func (e *finishedEvents) ArchiveStarted(archive string, files int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.started = append(e.started, archive)
+}
+
+func (e *finishedEvents) ArchiveFinished(result *Result) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.finished = append(e.finished, result.Archive)
+}
+
+func TestUnzipAll(t *testing.T) {
+	zip1 := createTestZip(t, []testFile{
+		{name: "small.txt", content: "s"},
+		{name: "shared.txt", content: "from part 1"},
+	})
+	defer os.Remove(zip1)
+	zip2 := createTestZip(t, []testFile{
+		{name: "large.txt", content: strings.Repeat("l", 4096)},
+		{name: "medium.txt", content: strings.Repeat("m", 512)},
+		{name: "shared.txt", content: "from part 2"},
+	})
+	defer os.Remove(zip2)
+	destDir := t.TempDir()
+
+	events := &finishedEvents{}
+	z := New(Options{Destination: destDir, Workers: 1, Events: events})
+	results, err := z.UnzipAll([]string{zip1, zip2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Archive != zip1 || results[1].Archive != zip2 {
+		t.Fatalf("results should be per archive in the order given, got %d", len(results))
+	}
+	if results[0].Extracted != 2 || results[1].Extracted != 3 {
+		t.Errorf("extracted %d and %d files, want 2 and 3", results[0].Extracted, results[1].Extracted)
+	}
+	for _, result := range results {
+		for _, log := range result.Logs {
+			if log.Archive != result.Archive {
+				t.Errorf("result of %s has a log of %s", result.Archive, log.Archive)
+			}
+		}
+	}
+	if len(events.started) != 2 || len(events.finished) != 2 {
+		t.Errorf("events started %d and finished %d archives, want 2", len(events.started), len(events.finished))
+	}
+
+	// The last archive wins a path both contain
+	data, _ := os.ReadFile(filepath.Join(destDir, "shared.txt"))
+	if string(data) != "from part 2" {
+		t.Errorf("shared.txt = %q, want the last archive's", data)
+	}
+
+	// Largest files go first, across archives
+	var order []string
+	for _, log := range z.GetLogs() {
+		if log.Status == "Extracted" {
+			order = append(order, log.Path)
+		}
+	}
+	if len(order) < 2 || order[0] != "large.txt" || order[1] != "medium.txt" {
+		t.Errorf("extraction order = %v, want large.txt and medium.txt first", order)
+	}
+}
+
+func TestUnzipAllRefusesUnreadableArchive(t *testing.T) {
+	zipPath := createTestZip(t, []testFile{{name: "a.txt", content: "a"}})
+	defer os.Remove(zipPath)
+	destDir := t.TempDir()
+
+	results, err := New(Options{Destination: destDir}).UnzipAll([]string{zipPath, filepath.Join(destDir, "missing.zip")})
+	if err == nil || results != nil {
+		t.Fatalf("UnzipAll = %v, %v; want an error", results, err)
+	}
+	if FileExists(filepath.Join(destDir, "a.txt")) {
+		t.Error("nothing should be extracted when an archive can't be read")
+	}
+}
@@ -0,0 +1,178 @@
+package takeout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrInsufficientSpace is returned when extraction stops because the
+// destination filesystem ran low on free space
+var ErrInsufficientSpace = errors.New("not enough free space at the destination")
+
+// SpaceCheck compares the space extraction needs on one filesystem with what
+// is available there
+type SpaceCheck struct {
+	Destinations []string // Mapping destinations on this filesystem
+	Needed       int64    // Bytes added, counting replaced files by how much they grow
+	Available    uint64
+	Reserve      uint64 // Free space to keep, from Options.MinFree
+}
+
+// Short reports whether extraction would eat into the reserve
+func (c SpaceCheck) Short() bool {
+	needed := uint64(max(c.Needed, 0))
+	return needed+c.Reserve > c.Available
+}
+
+// existingAncestor returns path or its nearest parent that exists, since the
+// destination may not be created yet
+func existingAncestor(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// CheckSpace works out how much space extracting the archives needs on each
+// destination filesystem. Destinations on the same filesystem are summed. It
+// returns no checks on platforms where free space can't be determined
+func (z *Extractor) CheckSpace(zipFiles []string) ([]SpaceCheck, error) {
+	roots := z.destinationRoots()
+	checks, err := z.newSpaceChecks(roots)
+	if checks == nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, zipFile := range zipFiles {
+		r, err := z.OpenArchive(zipFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", zipFile, err)
+		}
+		for _, f := range r.File {
+			destPath, include := z.shouldIncludeFile(f.Name)
+			if !include || f.FileInfo().IsDir() || seen[destPath] {
+				continue
+			}
+			seen[destPath] = true
+			check := checks[rootOf(roots, destPath)]
+			if check == nil {
+				continue
+			}
+			check.Needed += int64(f.UncompressedSize64)
+			if info, err := os.Stat(destPath); err == nil && !info.IsDir() {
+				// Files are rewritten in place, so only growth needs space
+				check.Needed -= info.Size()
+			}
+		}
+		r.Close()
+	}
+	return sortedChecks(checks), nil
+}
+
+// CheckPlanSpace works out how much space applying a plan needs on each
+// destination filesystem, from the entries it extracts or replaces
+func (z *Extractor) CheckPlanSpace(plan *Plan) ([]SpaceCheck, error) {
+	var roots []string
+	for _, m := range plan.mappings() {
+		roots = append(roots, m.Destination)
+	}
+	checks, err := z.newSpaceChecks(roots)
+	if checks == nil {
+		return nil, err
+	}
+
+	for _, e := range plan.Entries {
+		if e.Action != ActionExtract && e.Action != ActionReplace {
+			continue
+		}
+		check := checks[rootOf(roots, e.Destination)]
+		if check == nil {
+			continue
+		}
+		check.Needed += e.Size
+		if e.Existing.Exists && !e.Existing.IsDir {
+			check.Needed -= e.Existing.Size
+		}
+	}
+	return sortedChecks(checks), nil
+}
+
+// newSpaceChecks returns the check of the filesystem each root is on, shared
+// by roots on the same one. It returns nil on platforms where free space
+// can't be determined
+func (z *Extractor) newSpaceChecks(roots []string) (map[string]*SpaceCheck, error) {
+	byDevice := map[string]*SpaceCheck{}
+	checks := map[string]*SpaceCheck{}
+	for _, root := range roots {
+		available, device, err := diskSpace(existingAncestor(root))
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking free space for %s: %w", root, err)
+		}
+		check, ok := byDevice[device]
+		if !ok {
+			check = &SpaceCheck{Available: available, Reserve: z.minFree}
+			byDevice[device] = check
+		}
+		check.Destinations = append(check.Destinations, root)
+		checks[root] = check
+	}
+	return checks, nil
+}
+
+// sortedChecks returns each distinct check once, ordered by destination
+func sortedChecks(checks map[string]*SpaceCheck) []SpaceCheck {
+	var result []SpaceCheck
+	seen := map[*SpaceCheck]bool{}
+	for _, check := range checks {
+		if !seen[check] {
+			seen[check] = true
+			result = append(result, *check)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Destinations[0] < result[j].Destinations[0] })
+	return result
+}
+
+// rootOf returns the destination root a path lies in
+func rootOf(roots []string, path string) string {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(os.PathSeparator)) {
+			return root
+		}
+	}
+	return ""
+}
+
+// ensureSpace returns ErrInsufficientSpace when writing size bytes next to
+// destPath would leave less than the reserve free. Once that happens the
+// extractor stops, so remaining entries are not attempted
+func (z *Extractor) ensureSpace(destPath string, size int64) error {
+	if z.stopped.Load() {
+		return ErrInsufficientSpace
+	}
+	available, _, err := diskSpace(existingAncestor(filepath.Dir(destPath)))
+	if err != nil {
+		// Without free space information, rely on write errors instead
+		return nil
+	}
+	if uint64(max(size, 0))+z.minFree > available {
+		z.stopped.Store(true)
+		return fmt.Errorf("%w: %d bytes free, %d needed and %d reserved", ErrInsufficientSpace, available, size, z.minFree)
+	}
+	return nil
+}
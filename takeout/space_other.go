@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package takeout
+
+import "errors"
+
+// diskSpace is not supported on this platform, so space checks are skipped
+func diskSpace(path string) (uint64, string, error) {
+	return 0, "", errors.ErrUnsupported
+}
+
+func isNoSpace(err error) bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package takeout
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSpace(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "new.txt", content: strings.Repeat("n", 100)},
+		{name: "grown.txt", content: strings.Repeat("g", 100)},
+		{name: "dir", isDir: true},
+	})
+	defer os.Remove(zipPath)
+	os.WriteFile(filepath.Join(destDir, "grown.txt"), []byte(strings.Repeat("o", 40)), 0644)
+
+	checks, err := New(Options{Destination: filepath.Join(destDir, "not-yet-created")}).CheckSpace([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Needed != 200 || checks[0].Available == 0 || checks[0].Short() {
+		t.Errorf("Checks for a new folder = %+v, want 200 bytes needed and enough space", checks)
+	}
+
+	checks, err = New(Options{Destination: destDir, MinFree: 1 << 62}).CheckSpace([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Needed != 160 || !checks[0].Short() {
+		t.Errorf("Checks = %+v, want 160 bytes needed and short of the reserve", checks)
+	}
+}
+
+func TestCurrentFolderDestination(t *testing.T) {
+	dirTime := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	link := &zip.FileHeader{Name: "Photos/link", Modified: dirTime}
+	link.SetMode(os.ModeSymlink | 0777)
+	zipPath := createHeaderZip(t, []*zip.FileHeader{
+		{Name: "Photos/", Modified: dirTime},
+		{Name: "Photos/a.jpg", Modified: dirTime},
+		link,
+	}, map[string]string{"Photos/a.jpg": "jpeg", "Photos/link": "a.jpg"})
+
+	destDir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(destDir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	z := New(Options{Destination: ".", Workers: 1})
+	checks, err := z.CheckSpace([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Needed != int64(len("jpeg")+len("a.jpg")) {
+		t.Errorf("Checks = %+v, want the size of both files needed", checks)
+	}
+	result, err := z.Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 2 {
+		t.Errorf("Extracted %d entries, want the file and the symlink", result.Extracted)
+	}
+	if data, err := os.ReadFile(filepath.Join(destDir, "Photos", "link")); err != nil || string(data) != "jpeg" {
+		t.Errorf("Symlink reads %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "Photos")); err != nil || !info.ModTime().Equal(dirTime) {
+		t.Errorf("Photos folder time not restored: %v", err)
+	}
+}
+
+func TestCheckPlanSpace(t *testing.T) {
+	destDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "new.txt", content: strings.Repeat("n", 100)},
+		{name: "grown.txt", content: strings.Repeat("g", 100)},
+		{name: "same.txt", content: "same"},
+	})
+	defer os.Remove(zipPath)
+	os.WriteFile(filepath.Join(destDir, "grown.txt"), []byte(strings.Repeat("o", 40)), 0644)
+
+	plan, err := New(Options{Destination: destDir}).Plan([]string{zipPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries the plan skips need no space
+	for i := range plan.Entries {
+		if plan.Entries[i].Entry == "same.txt" {
+			plan.Entries[i].Action = ActionSkip
+		}
+	}
+
+	checks, err := New(Options{MinFree: 1 << 62}).CheckPlanSpace(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Needed != 160 || !checks[0].Short() {
+		t.Errorf("Checks = %+v, want 160 bytes needed and short of the reserve", checks)
+	}
+}
+
+func TestUnzipStopsWhenLowOnSpace(t *testing.T) {
+	zipPath, extractDir, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	result, err := New(Options{Destination: extractDir, Workers: 1, MinFree: 1 << 62}).Unzip(zipPath)
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("Unzip error = %v, want ErrInsufficientSpace", err)
+	}
+	if result.Stopped != 1 || result.Failed != 0 || result.Extracted != 0 {
+		t.Errorf("Result = %+v, want one stopped file and no retries or failures", result)
+	}
+	for _, log := range result.Logs {
+		if log.Status == "Retry" {
+			t.Errorf("Unexpected retry: %+v", log)
+		}
+	}
+}
+
+func TestSplitStopsWhenLowOnSpace(t *testing.T) {
+	zipPath := createTestZip(t, []testFile{
+		{name: "Mail/All mail.mbox", content: testMbox},
+		{name: "Contacts/All Contacts.vcf", content: testVCards},
+	})
+	defer os.Remove(zipPath)
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		extractDir := t.TempDir()
+		z := New(Options{Destination: extractDir, Workers: 1, MinFree: 1 << 62})
+		job := entryJob{archive: zipPath, f: f, destPath: filepath.Join(extractDir, filepath.FromSlash(f.Name))}
+		split := z.splitItemsEntry
+		if isMboxEntry(f.Name) {
+			split = z.extractMbox
+		}
+		if err := split(job); !errors.Is(err, ErrInsufficientSpace) {
+			t.Errorf("Splitting %s: err = %v, want ErrInsufficientSpace", f.Name, err)
+		}
+		// Only the first item is attempted
+		if logs := z.GetLogs(); len(logs) != 1 || logs[0].Status != "Stopped" {
+			t.Errorf("Splitting %s logged %+v, want a single Stopped", f.Name, logs)
+		}
+	}
+}
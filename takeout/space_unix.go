@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd
+
+package takeout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskSpace returns the space available to unprivileged users on the
+// filesystem holding path, and an identifier of that filesystem
+func diskSpace(path string) (uint64, string, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, "", err
+	}
+	var device string
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		device = fmt.Sprint(sys.Dev)
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), device, nil
+}
+
+// isNoSpace reports whether a write failed because the filesystem is full
+func isNoSpace(err error) bool {
+	return errors.Is(err, unix.ENOSPC) || errors.Is(err, unix.EDQUOT)
+}
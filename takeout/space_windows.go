@@ -0,0 +1,34 @@
+//go:build windows
+
+package takeout
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskSpace returns the space available to the current user on the volume
+// holding path, and an identifier of that volume
+func diskSpace(path string) (uint64, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, "", err
+	}
+	p, err := windows.UTF16PtrFromString(abs)
+	if err != nil {
+		return 0, "", err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &free); err != nil {
+		return 0, "", err
+	}
+	return available, strings.ToLower(filepath.VolumeName(abs)), nil
+}
+
+// isNoSpace reports whether a write failed because the volume is full
+func isNoSpace(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
@@ -0,0 +1,177 @@
+package takeout
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// specialModes are the entry types that are never created on disk
+const specialModes = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeIrregular
+
+// unusualModes are mode bits dropped from extracted files
+const unusualModes = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// maxLinkTarget bounds how much of a symlink entry is read as its target
+const maxLinkTarget = 4096
+
+// isSymlinkEntry reports whether a zip entry stores a symlink, whose content
+// is the link target
+func isSymlinkEntry(f *zip.File) bool {
+	return f.Mode()&os.ModeSymlink != 0
+}
+
+// specialKind names the type of an entry that can't be extracted as a file,
+// or returns empty for regular files and symlinks
+func specialKind(f *zip.File) string {
+	mode := f.Mode()
+	switch {
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&specialModes != 0:
+		return "irregular file"
+	}
+	return ""
+}
+
+// droppedModes describes the unusual mode bits of an entry that are not
+// applied to the extracted file
+func droppedModes(f *zip.File) string {
+	mode := f.Mode()
+	if mode&unusualModes == 0 {
+		return ""
+	}
+	var dropped []string
+	if mode&os.ModeSetuid != 0 {
+		dropped = append(dropped, "setuid")
+	}
+	if mode&os.ModeSetgid != 0 {
+		dropped = append(dropped, "setgid")
+	}
+	if mode&os.ModeSticky != 0 {
+		dropped = append(dropped, "sticky")
+	}
+	return fmt.Sprintf("Dropped %s mode bits", strings.Join(dropped, " and "))
+}
+
+// readLinkTarget returns the target stored in a symlink entry
+func readLinkTarget(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 || len(data) > maxLinkTarget {
+		return "", fmt.Errorf("symlink target is empty or too long")
+	}
+	return string(data), nil
+}
+
+// checkLinkTarget returns an error unless a symlink at destPath pointing to
+// target stays inside the destination it is extracted to. Links must be
+// relative, may only climb with leading "..", and can't be placed below
+// another symlink, so later entries can't be written outside the destination
+// through them
+func (z *Extractor) checkLinkTarget(destPath, target string) error {
+	target = filepath.FromSlash(target)
+	if filepath.IsAbs(target) || filepath.VolumeName(target) != "" || strings.HasPrefix(target, string(os.PathSeparator)) {
+		return fmt.Errorf("symlink target %s is absolute", target)
+	}
+	climbing := true
+	for _, part := range strings.Split(target, string(os.PathSeparator)) {
+		if part != ".." {
+			climbing = part == "." && climbing
+			continue
+		}
+		if !climbing {
+			return fmt.Errorf("symlink target %s climbs after descending", target)
+		}
+	}
+
+	root := rootOf(z.destinationRoots(), destPath)
+	if root == "" {
+		return fmt.Errorf("symlink is outside the destination")
+	}
+	resolved := filepath.Join(filepath.Dir(destPath), target)
+	if resolved != root && !strings.HasPrefix(resolved, root+string(os.PathSeparator)) {
+		return fmt.Errorf("symlink target %s points outside %s", target, root)
+	}
+	for dir := filepath.Dir(destPath); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("folder %s is a symlink", z.displayPath(dir))
+		}
+	}
+	return nil
+}
+
+// extractSymlink recreates a symlink entry, unless symlinks are disabled or
+// the link would point outside the destination
+func (z *Extractor) extractSymlink(job entryJob) error {
+	f, destPath := job.f, job.destPath
+	log := func(status, reason string) {
+		z.logExtraction(job.archive, f.Name, destPath, int64(f.UncompressedSize64), status, reason)
+	}
+
+	target, err := readLinkTarget(f)
+	if err != nil {
+		log("Failed", err.Error())
+		return err
+	}
+	if z.noSymlinks {
+		log("Skipped", fmt.Sprintf("Symlink to %s not created, symlinks are disabled", target))
+		return nil
+	}
+	if err := z.checkLinkTarget(destPath, target); err != nil {
+		log("Skipped", fmt.Sprintf("Refusing symlink: %v", err))
+		return nil
+	}
+
+	existing, err := os.Readlink(destPath)
+	if err == nil && existing == filepath.FromSlash(target) {
+		log("Skipped", "Symlink already exists and matches")
+		return nil
+	}
+	_, statErr := os.Lstat(destPath)
+	if z.dryRun {
+		reason := "Symlink does not exist"
+		if statErr == nil {
+			reason = "Symlink target differs"
+		}
+		log("Would Extract", reason)
+		return nil
+	}
+	if statErr == nil {
+		log("Replacing", "Symlink target differs")
+		if err := os.Remove(destPath); err != nil {
+			log("Failed", err.Error())
+			return err
+		}
+	}
+
+	err = z.mkdirAll(filepath.Dir(destPath))
+	if err == nil {
+		err = os.Symlink(filepath.FromSlash(target), destPath)
+	}
+	if err == nil {
+		err = z.applyOwnership(destPath, 0)
+	}
+	if err != nil {
+		log("Failed", err.Error())
+		return err
+	}
+	log("Extracted", "Symlink to "+target)
+	return nil
+}
@@ -0,0 +1,102 @@
+package takeout
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createModeZip writes entries with the given modes, such as symlinks whose
+// content is their target
+func createModeZip(t *testing.T, entries map[string]os.FileMode, content map[string]string) string {
+	t.Helper()
+	var headers []*zip.FileHeader
+	for name, mode := range entries {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(mode)
+		headers = append(headers, header)
+	}
+	return createHeaderZip(t, headers, content)
+}
+
+func TestUnzipSpecialEntries(t *testing.T) {
+	zipPath := createModeZip(t, map[string]os.FileMode{
+		"a.txt":      0644,
+		"link":       os.ModeSymlink | 0777,
+		"dir/up":     os.ModeSymlink | 0777,
+		"escape":     os.ModeSymlink | 0777,
+		"absolute":   os.ModeSymlink | 0777,
+		"sideways":   os.ModeSymlink | 0777,
+		"fifo":       os.ModeNamedPipe | 0644,
+		"setuid.bin": os.ModeSetuid | 0755,
+	}, map[string]string{
+		"a.txt":      "hello",
+		"link":       "a.txt",
+		"dir/up":     "../a.txt",
+		"escape":     "../outside",
+		"absolute":   "/etc/passwd",
+		"sideways":   "dir/../../outside",
+		"setuid.bin": "binary",
+	})
+
+	destDir := t.TempDir()
+	result, err := New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 4 || result.Skipped != 4 {
+		t.Errorf("Extracted %d and skipped %d, want 4 and 4", result.Extracted, result.Skipped)
+	}
+	for _, link := range []string{"link", "dir/up"} {
+		if data, err := os.ReadFile(filepath.Join(destDir, link)); err != nil || string(data) != "hello" {
+			t.Errorf("Symlink %s reads %q, %v", link, data, err)
+		}
+	}
+	for _, name := range []string{"escape", "absolute", "sideways", "fifo"} {
+		if _, err := os.Lstat(filepath.Join(destDir, name)); err == nil {
+			t.Errorf("%s should not be created", name)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "setuid.bin")); err != nil || info.Mode() != 0755 {
+		t.Errorf("setuid.bin mode = %v, %v; want -rwxr-xr-x", info.Mode(), err)
+	}
+	for _, log := range result.Logs {
+		if log.Path == "setuid.bin" && !strings.Contains(log.Reason, "setuid") {
+			t.Errorf("Dropped mode bits not logged: %q", log.Reason)
+		}
+	}
+
+	// Links are compared by target on the next run
+	result, err = New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 0 {
+		t.Errorf("Rerun extracted %d files, want 0", result.Extracted)
+	}
+
+	destDir = t.TempDir()
+	if _, err := New(Options{Destination: destDir, NoSymlinks: true, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(destDir, "link")); err == nil {
+		t.Error("Symlink created with NoSymlinks")
+	}
+}
+
+func TestSymlinkParentRefused(t *testing.T) {
+	destDir := t.TempDir()
+	// A link from an earlier run pointing back at the destination itself
+	if err := os.Symlink(".", filepath.Join(destDir, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	z := New(Options{Destination: destDir})
+	if err := z.checkLinkTarget(filepath.Join(destDir, "loop", "sub", "x"), "../../outside"); err == nil {
+		t.Error("Link below a symlink was allowed")
+	}
+	if err := z.checkLinkTarget(filepath.Join(destDir, "sub", "x"), "../y"); err != nil {
+		t.Errorf("Link inside the destination refused: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package takeout
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pimItem is a single contact or calendar component split out of a file
+type pimItem struct {
+	key     string // UID, or a fallback identity when the item has none
+	lines   []string
+	modTime time.Time
+}
+
+// isItemsEntry reports whether a zip entry is a contacts or calendar file
+// that splitItemsEntry can split
+func isItemsEntry(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".vcf", ".ics":
+		return true
+	}
+	return false
+}
+
+// splitItemsEntry writes one .vcf per contact or one .ics per event from the
+// contacts or calendar entry of job into a folder next to its destination
+func (z *Extractor) splitItemsEntry(job entryJob) error {
+	f := job.f
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	ext := strings.ToLower(filepath.Ext(f.Name))
+	var files map[string][]byte
+	var times map[string]time.Time
+	if ext == ".vcf" {
+		files, times, err = splitVCards(rc, f.Modified, z.itemName)
+	} else {
+		files, times, err = splitCalendar(rc, f.Modified, z.itemName)
+	}
+	if err != nil {
+		return fmt.Errorf("splitting %s: %w", f.Name, err)
+	}
+
+	folder := derivedFolder(job.destPath)
+	var failed int
+	for name, data := range files {
+		if err := z.writeDerivedFile(job, filepath.Join(folder, name), data, times[name]); err != nil {
+			if errors.Is(err, ErrInsufficientSpace) {
+				return fmt.Errorf("splitting %s: %w", f.Name, err)
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to write %d items from %s", failed, f.Name)
+	}
+	return nil
+}
+
+// splitVCards splits a vCard file into one file per contact, keyed by the
+// output file name as sanitize makes it
+func splitVCards(r io.Reader, fallbackTime time.Time, sanitize func(string) string) (map[string][]byte, map[string]time.Time, error) {
+	items, _, err := readComponents(r, "VCARD", fallbackTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make(map[string][]byte)
+	times := make(map[string]time.Time)
+	for _, item := range items {
+		name := uniqueItemName(files, item, ".vcf", sanitize)
+		files[name] = []byte(strings.Join(item.lines, "\r\n") + "\r\n")
+		times[name] = item.modTime
+	}
+	return files, times, nil
+}
+
+// splitCalendar splits an iCalendar file into one calendar per event UID.
+// Each output keeps the calendar properties and time zones of the original,
+// and recurrence overrides sharing a UID stay together with their master event
+func splitCalendar(r io.Reader, fallbackTime time.Time, sanitize func(string) string) (map[string][]byte, map[string]time.Time, error) {
+	items, header, err := readComponents(r, "VCALENDAR", fallbackTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var timezones []string
+	var order []string
+	grouped := make(map[string]*pimItem)
+	for _, item := range items {
+		if strings.EqualFold(item.lines[0], "BEGIN:VTIMEZONE") {
+			timezones = append(timezones, item.lines...)
+			continue
+		}
+		if existing, ok := grouped[item.key]; ok {
+			existing.lines = append(existing.lines, item.lines...)
+			if item.modTime.After(existing.modTime) {
+				existing.modTime = item.modTime
+			}
+			continue
+		}
+		grouped[item.key] = &pimItem{key: item.key, lines: item.lines, modTime: item.modTime}
+		order = append(order, item.key)
+	}
+
+	files := make(map[string][]byte)
+	times := make(map[string]time.Time)
+	for _, key := range order {
+		item := grouped[key]
+		var b strings.Builder
+		b.WriteString("BEGIN:VCALENDAR\r\n")
+		for _, line := range header {
+			b.WriteString(line + "\r\n")
+		}
+		for _, line := range timezones {
+			b.WriteString(line + "\r\n")
+		}
+		for _, line := range item.lines {
+			b.WriteString(line + "\r\n")
+		}
+		b.WriteString("END:VCALENDAR\r\n")
+
+		name := uniqueItemName(files, *item, ".ics", sanitize)
+		files[name] = []byte(b.String())
+		times[name] = item.modTime
+	}
+	return files, times, nil
+}
+
+// readComponents reads the components nested one level inside container
+// (or the container blocks themselves for vCards) and the properties of the
+// container that appear outside any component
+func readComponents(r io.Reader, container string, fallbackTime time.Time) ([]pimItem, []string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var items []pimItem
+	var header []string
+	var current *pimItem
+	depth := 0
+	itemDepth := 1
+	if container == "VCARD" {
+		itemDepth = 0
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		upper := strings.ToUpper(line)
+		isBegin := strings.HasPrefix(upper, "BEGIN:")
+		isEnd := strings.HasPrefix(upper, "END:")
+
+		if isBegin && depth == itemDepth {
+			current = &pimItem{modTime: fallbackTime}
+		}
+		if isBegin {
+			depth++
+		}
+
+		switch {
+		case current != nil:
+			current.lines = append(current.lines, line)
+			if depth == itemDepth+1 {
+				applyItemProperty(current, line)
+			}
+		case depth == 1 && !isBegin && !isEnd && container != "VCARD":
+			header = append(header, line)
+		}
+
+		if isEnd {
+			depth--
+			if depth == itemDepth && current != nil {
+				if current.key == "" {
+					sum := sha256.Sum256([]byte(strings.Join(current.lines, "\n")))
+					current.key = fmt.Sprintf("%x", sum[:8])
+				}
+				items = append(items, *current)
+				current = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return items, header, nil
+}
+
+// applyItemProperty picks the identity and modification time of an item
+// from its UID, FN, REV and LAST-MODIFIED properties. Folded continuation
+// lines are ignored, which only shortens overly long values
+func applyItemProperty(item *pimItem, line string) {
+	if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		return
+	}
+	sep := strings.Index(line, ":")
+	if sep < 0 {
+		return
+	}
+	name := strings.ToUpper(line[:sep])
+	if i := strings.Index(name, ";"); i >= 0 {
+		name = name[:i]
+	}
+	value := strings.TrimSpace(line[sep+1:])
+
+	switch name {
+	case "UID":
+		item.key = value
+	case "FN":
+		// Google Contacts exports have no UID, so fall back to the name
+		if item.key == "" {
+			item.key = value
+		}
+	case "REV", "LAST-MODIFIED":
+		if t, ok := parseItemTime(value); ok {
+			item.modTime = t
+		}
+	}
+}
+
+func parseItemTime(value string) (time.Time, bool) {
+	for _, layout := range []string{"20060102T150405Z", "2006-01-02T15:04:05Z", time.RFC3339, "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// uniqueItemName derives a file name from the item key and sanitises it,
+// adding a content hash when another item in the same file already produced
+// that name
+func uniqueItemName(files map[string][]byte, item pimItem, ext string, sanitize func(string) string) string {
+	base := safeItemName(item.key)
+	name := sanitize(base + ext)
+	if _, taken := files[name]; taken {
+		sum := sha256.Sum256([]byte(strings.Join(item.lines, "\n")))
+		name = sanitize(fmt.Sprintf("%s-%x%s", base, sum[:4], ext))
+	}
+	return name
+}
+
+// itemName sanitises the name of a split out item for the name profile and
+// normalization, like the names of entries
+func (z *Extractor) itemName(name string) string {
+	return z.normalization.apply(z.profile.sanitizeName(name))
+}
+
+// safeItemName maps a UID or name to a file name, replacing characters that
+// aren't safe across filesystems and hashing names that are too long
+func safeItemName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, key)
+	name = strings.Trim(name, ". ")
+	if name == "" || len(name) > 100 {
+		sum := sha256.Sum256([]byte(key))
+		prefix := name
+		if len(prefix) > 60 {
+			prefix = strings.ToValidUTF8(prefix[:60], "")
+		}
+		name = fmt.Sprintf("%s%x", prefix, sum[:8])
+	}
+	return name
+}
@@ -0,0 +1,144 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testVCards = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"FN:Ada Lovelace\r\n" +
+	"EMAIL:ada@example.com\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:urn:uuid:1234\r\n" +
+	"FN:Alan Turing\r\n" +
+	"REV:2023-01-02T10:00:00Z\r\n" +
+	"END:VCARD\r\n"
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Google Inc//Google Calendar 70.9054//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Stockholm\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:event-1@google.com\r\n" +
+	"SUMMARY:Weekly\r\n" +
+	"LAST-MODIFIED:20230102T100000Z\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"UID:alarm-1\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:event-1@google.com\r\n" +
+	"RECURRENCE-ID:20230109T100000Z\r\n" +
+	"SUMMARY:Weekly (moved)\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:event-2@google.com\r\n" +
+	"SUMMARY:Once\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestSplitVCards(t *testing.T) {
+	fallback := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	files, times, err := splitVCards(strings.NewReader(testVCards), fallback, ProfilePOSIX.sanitizeName)
+	if err != nil {
+		t.Fatalf("splitVCards() error = %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Got %d contacts, want 2: %v", len(files), files)
+	}
+	if _, ok := files["Ada Lovelace.vcf"]; !ok {
+		t.Errorf("Contact without UID should be named after FN, got %v", files)
+	}
+	data, ok := files["urn_uuid_1234.vcf"]
+	if !ok {
+		t.Fatalf("Contact with UID should be named after the UID, got %v", files)
+	}
+	if !strings.HasPrefix(string(data), "BEGIN:VCARD\r\n") || !strings.HasSuffix(string(data), "END:VCARD\r\n") {
+		t.Errorf("Contact not a complete vCard: %q", data)
+	}
+	if want := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC); !times["urn_uuid_1234.vcf"].Equal(want) {
+		t.Errorf("Contact time = %v, want REV %v", times["urn_uuid_1234.vcf"], want)
+	}
+	if !times["Ada Lovelace.vcf"].Equal(fallback) {
+		t.Errorf("Contact without REV should use fallback time, got %v", times["Ada Lovelace.vcf"])
+	}
+}
+
+func TestSplitItemNames(t *testing.T) {
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:CON\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Cafe\u0301\r\nEND:VCARD\r\n"
+	z := New(Options{Profile: ProfileWindows, Normalize: NormalizeNFC})
+	files, _, err := splitVCards(strings.NewReader(vcard), time.Now(), z.itemName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"CON_.vcf", "Caf\u00e9.vcf"} {
+		if _, ok := files[want]; !ok {
+			t.Errorf("Split names = %v, want %s", files, want)
+		}
+	}
+}
+
+func TestSplitCalendar(t *testing.T) {
+	files, _, err := splitCalendar(strings.NewReader(testCalendar), time.Now(), ProfilePOSIX.sanitizeName)
+	if err != nil {
+		t.Fatalf("splitCalendar() error = %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Got %d events, want 2: %v", len(files), files)
+	}
+
+	event := string(files["event-1@google.com.ics"])
+	for _, want := range []string{"PRODID:-//Google Inc", "TZID:Europe/Stockholm", "SUMMARY:Weekly\r\n", "SUMMARY:Weekly (moved)", "UID:alarm-1"} {
+		if !strings.Contains(event, want) {
+			t.Errorf("Recurring event missing %q:\n%s", want, event)
+		}
+	}
+	if strings.Contains(event, "SUMMARY:Once") {
+		t.Error("Recurring event file should not contain other events")
+	}
+	if strings.Count(event, "BEGIN:VCALENDAR") != 1 || !strings.HasSuffix(event, "END:VCALENDAR\r\n") {
+		t.Errorf("Event file not a single calendar:\n%s", event)
+	}
+}
+
+func TestSplitItemsExtraction(t *testing.T) {
+	extractDir := t.TempDir()
+	zipPath := createTestZip(t, []testFile{
+		{name: "Contacts/All Contacts/All Contacts.vcf", content: testVCards},
+	})
+	defer os.Remove(zipPath)
+
+	extractor := New(Options{Destination: extractDir, Workers: 1, SplitItems: true})
+	if _, err := extractor.Unzip(zipPath); err != nil {
+		t.Fatalf("Unzip() error = %v", err)
+	}
+
+	contactsDir := filepath.Join(extractDir, "Contacts", "All Contacts")
+	if !FileExists(filepath.Join(contactsDir, "All Contacts.vcf")) {
+		t.Error("Original contacts file should still be extracted")
+	}
+	for _, name := range []string{"Ada Lovelace.vcf", "urn_uuid_1234.vcf"} {
+		if !FileExists(filepath.Join(contactsDir, "All Contacts", name)) {
+			t.Errorf("Expected split contact %s", name)
+		}
+	}
+
+	rerun := New(Options{Destination: extractDir, Workers: 1, SplitItems: true})
+	if _, err := rerun.Unzip(zipPath); err != nil {
+		t.Fatalf("Unzip() rerun error = %v", err)
+	}
+	for _, log := range rerun.GetLogs() {
+		if log.Status != "Skipped" {
+			t.Errorf("Rerun log for %s = %q, want Skipped", log.DestPath, log.Status)
+		}
+	}
+}
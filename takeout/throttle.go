@@ -0,0 +1,167 @@
+package takeout
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minBurst is the least a throttle lets through at once, so a limit below
+// the size of a read still makes progress
+const minBurst = 256 * 1024
+
+// bucket is a token bucket refilled at rate tokens per second. Taking more
+// than it holds puts it in debt, which later callers wait out
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take removes n tokens from a bucket holding at most burst, and returns how
+// long to wait before using them
+func (b *bucket) take(n float64, now time.Time, burst float64) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, burst)
+	} else {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// DailyWindow is a time of day range, such as 08:00-18:00. It may wrap
+// past midnight
+type DailyWindow struct {
+	Start time.Duration // Since midnight
+	End   time.Duration
+}
+
+// ParseDailyWindow parses a range such as 08:00-18:00 or 22:00-06:30
+func ParseDailyWindow(s string) (DailyWindow, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return DailyWindow{}, fmt.Errorf("invalid time range %q, want such as 08:00-18:00", s)
+	}
+	var w DailyWindow
+	for _, part := range []struct {
+		text string
+		into *time.Duration
+	}{{start, &w.Start}, {end, &w.End}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.text))
+		if err != nil {
+			return DailyWindow{}, fmt.Errorf("invalid time range %q, want such as 08:00-18:00", s)
+		}
+		*part.into = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return w, nil
+}
+
+// Contains reports whether the local time of t is within the window
+func (w DailyWindow) Contains(t time.Time) bool {
+	day := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start <= w.End {
+		return day >= w.Start && day < w.End
+	}
+	return day >= w.Start || day < w.End
+}
+
+func (w DailyWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// Throttle limits how fast extraction writes, in bytes and in files per
+// second, so it can run alongside other work on the same disk. The limits can
+// be changed while extraction runs. It is safe for concurrent use
+type Throttle struct {
+	mutex    sync.Mutex
+	bytes    bucket
+	files    bucket
+	schedule *DailyWindow // Limits only apply within it, or always when nil
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+// NewThrottle returns a throttle writing at most maxRate bytes and maxFiles
+// files per second. Zero means no limit
+func NewThrottle(maxRate, maxFiles float64) *Throttle {
+	return &Throttle{
+		bytes: bucket{rate: maxRate},
+		files: bucket{rate: maxFiles},
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+// SetSchedule makes the limits only apply within a daily window, such as
+// working hours, and lifts them outside it
+func (t *Throttle) SetSchedule(w DailyWindow) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.schedule = &w
+}
+
+// Scale multiplies the limits that are set by factor, such as 0.5 to halve
+// them
+func (t *Throttle) Scale(factor float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.bytes.rate *= factor
+	t.files.rate *= factor
+}
+
+// Limits returns the bytes and files per second allowed, zero when unlimited
+func (t *Throttle) Limits() (maxRate, maxFiles float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.bytes.rate, t.files.rate
+}
+
+// wait blocks until writing n more bytes, and a file when file is set, keeps
+// within the limits, and returns how long it waited. A nil throttle never
+// waits
+func (t *Throttle) wait(n int64, file bool) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mutex.Lock()
+	now := t.now()
+	if t.schedule != nil && !t.schedule.Contains(now) {
+		t.mutex.Unlock()
+		return 0
+	}
+	var delay time.Duration
+	if t.bytes.rate > 0 && n > 0 {
+		delay = t.bytes.take(float64(n), now, max(t.bytes.rate, minBurst))
+	}
+	if t.files.rate > 0 && file {
+		delay = max(delay, t.files.take(1, now, max(t.files.rate, 1)))
+	}
+	t.mutex.Unlock()
+	if delay > 0 {
+		t.sleep(delay)
+	}
+	return delay
+}
+
+// throttledReader waits on a throttle for every read, adding up how long
+type throttledReader struct {
+	r        io.Reader
+	throttle *Throttle
+	waited   time.Duration
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.waited += r.throttle.wait(int64(n), false)
+	return n, err
+}
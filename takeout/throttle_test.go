@@ -0,0 +1,130 @@
+package takeout
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock makes a throttle sleep on a clock that only moves when it does
+func fakeClock(t *Throttle, start time.Time) *time.Duration {
+	now, slept := start, new(time.Duration)
+	t.now = func() time.Time { return now }
+	t.sleep = func(d time.Duration) {
+		*slept += d
+		now = now.Add(d)
+	}
+	return slept
+}
+
+func TestThrottleRate(t *testing.T) {
+	throttle := NewThrottle(1<<20, 0) // 1MB/s
+	slept := fakeClock(throttle, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+
+	// The first megabyte is the burst, the next three take a second each
+	for i := 0; i < 4*32; i++ {
+		throttle.wait(32*1024, false)
+	}
+	if *slept != 3*time.Second {
+		t.Errorf("writing 4MB at 1MB/s slept %v, want 3s", *slept)
+	}
+
+	throttle = NewThrottle(1<<20, 0)
+	throttle.Scale(2)
+	slept = fakeClock(throttle, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	for i := 0; i < 4*32; i++ {
+		throttle.wait(32*1024, false)
+	}
+	if *slept != time.Second {
+		t.Errorf("writing 4MB at 2MB/s slept %v, want 1s", *slept)
+	}
+	if maxRate, _ := throttle.Limits(); maxRate != 2<<20 {
+		t.Errorf("rate after doubling = %v, want 2MB/s", maxRate)
+	}
+
+	var unlimited *Throttle
+	unlimited.wait(1<<30, true) // Must not block or panic
+}
+
+func TestThrottleFiles(t *testing.T) {
+	throttle := NewThrottle(0, 10)
+	slept := fakeClock(throttle, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	for i := 0; i < 30; i++ {
+		throttle.wait(0, true)
+	}
+	if *slept != 2*time.Second {
+		t.Errorf("writing 30 files at 10 files/s slept %v, want 2s", *slept)
+	}
+}
+
+func TestThrottleSchedule(t *testing.T) {
+	w, err := ParseDailyWindow("08:00-18:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle := NewThrottle(0, 1)
+	throttle.SetSchedule(w)
+	slept := fakeClock(throttle, time.Date(2024, 1, 1, 22, 0, 0, 0, time.Local))
+	for i := 0; i < 10; i++ {
+		throttle.wait(0, true)
+	}
+	if *slept != 0 {
+		t.Errorf("throttle outside its hours slept %v", *slept)
+	}
+}
+
+func TestDailyWindow(t *testing.T) {
+	day := func(hour, minute int) time.Time { return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local) }
+	tests := []struct {
+		window string
+		at     time.Time
+		want   bool
+	}{
+		{"08:00-18:00", day(8, 0), true},
+		{"08:00-18:00", day(17, 59), true},
+		{"08:00-18:00", day(18, 0), false},
+		{"22:00-06:30", day(23, 0), true},
+		{"22:00-06:30", day(6, 0), true},
+		{"22:00-06:30", day(12, 0), false},
+	}
+	for _, tt := range tests {
+		w, err := ParseDailyWindow(tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Contains(tt.at); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.at.Format("15:04"), got, tt.want)
+		}
+		if w.String() != tt.window {
+			t.Errorf("String() = %s, want %s", w, tt.window)
+		}
+	}
+	for _, s := range []string{"8-18", "08:00", "25:00-26:00"} {
+		if _, err := ParseDailyWindow(s); err == nil {
+			t.Errorf("ParseDailyWindow(%q) should fail", s)
+		}
+	}
+}
+
+func TestExtractThrottled(t *testing.T) {
+	zipPath := createTestZip(t, []testFile{
+		{name: "a.txt", content: strings.Repeat("a", 512*1024)},
+		{name: "b.txt", content: strings.Repeat("b", 512*1024)},
+	})
+	defer os.Remove(zipPath)
+
+	throttle := NewThrottle(256*1024, 0)
+	slept := fakeClock(throttle, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	result, err := New(Options{Destination: t.TempDir(), Workers: 1, Throttle: throttle}).Unzip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extracted != 2 {
+		t.Errorf("extracted %d files, want 2", result.Extracted)
+	}
+	// 1MB at 256KB/s, less the first 256KB burst
+	if *slept != 3*time.Second {
+		t.Errorf("extraction slept %v, want 3s", *slept)
+	}
+}
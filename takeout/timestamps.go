@@ -0,0 +1,178 @@
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"time"
+)
+
+// Extra field IDs holding timestamps
+const (
+	ntfsExtraID    = 0x000a // NTFS: modification, access and creation time in 100ns units
+	extTimeExtraID = 0x5455 // Info-ZIP extended timestamp: Unix seconds
+)
+
+// Precision of the timestamps a zip entry can carry
+const (
+	dosTimePrecision  = 2 * time.Second
+	unixTimePrecision = time.Second
+	ntfsTimePrecision = 100 * time.Nanosecond
+)
+
+// EntryTimes are the timestamps stored for a zip entry. Times the archive
+// doesn't have are zero
+type EntryTimes struct {
+	Modified  time.Time
+	Accessed  time.Time
+	Created   time.Time
+	Precision time.Duration // How precisely Modified is stored
+}
+
+// ReadEntryTimes returns the timestamps of an entry from its NTFS or extended
+// timestamp extra fields, falling back to the 2-second DOS time
+func ReadEntryTimes(f *zip.File) EntryTimes {
+	times := EntryTimes{Modified: f.Modified, Precision: dosTimePrecision}
+	var unset *EntryTimes // NTFS times without a modification time
+	extra := f.Extra
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+
+		switch tag {
+		case ntfsExtraID:
+			t, ok := parseNTFSTimes(field)
+			if ok && t.Modified.IsZero() {
+				// An unset FILETIME leaves the DOS or extended time in charge
+				unset = &t
+			} else if ok {
+				// NTFS times are the most precise, so they win
+				t.Precision = ntfsTimePrecision
+				return t
+			}
+		case extTimeExtraID:
+			if t, ok := parseExtTimes(field); ok {
+				times = t
+			}
+		}
+	}
+	if unset != nil {
+		if times.Accessed.IsZero() {
+			times.Accessed = unset.Accessed
+		}
+		if times.Created.IsZero() {
+			times.Created = unset.Created
+		}
+	}
+	return times
+}
+
+// parseNTFSTimes reads the timestamp attribute of an NTFS extra field: four
+// reserved bytes, then tagged attributes, where tag 1 holds the three times as
+// Windows FILETIMEs
+func parseNTFSTimes(field []byte) (EntryTimes, bool) {
+	if len(field) < 4 {
+		return EntryTimes{}, false
+	}
+	field = field[4:]
+	for len(field) >= 4 {
+		tag := binary.LittleEndian.Uint16(field[0:2])
+		size := int(binary.LittleEndian.Uint16(field[2:4]))
+		if len(field) < 4+size {
+			break
+		}
+		attr := field[4 : 4+size]
+		field = field[4+size:]
+		if tag != 1 || size < 24 {
+			continue
+		}
+		return EntryTimes{
+			Modified: fileTime(binary.LittleEndian.Uint64(attr[0:8])),
+			Accessed: fileTime(binary.LittleEndian.Uint64(attr[8:16])),
+			Created:  fileTime(binary.LittleEndian.Uint64(attr[16:24])),
+		}, true
+	}
+	return EntryTimes{}, false
+}
+
+// fileTime converts a Windows FILETIME, 100ns intervals since 1601, or
+// returns zero when it is unset
+func fileTime(ft uint64) time.Time {
+	const epochDelta = 116444736000000000 // 100ns intervals from 1601 to 1970
+	if ft <= epochDelta {
+		return time.Time{}
+	}
+	ft -= epochDelta
+	return time.Unix(int64(ft/1e7), int64(ft%1e7)*100).UTC()
+}
+
+// parseExtTimes reads an extended timestamp field: a flags byte saying which
+// of the modification, access and creation times follow as Unix seconds. The
+// central directory only ever has the modification time
+func parseExtTimes(field []byte) (EntryTimes, bool) {
+	if len(field) < 1 {
+		return EntryTimes{}, false
+	}
+	flags, field := field[0], field[1:]
+	times := EntryTimes{Precision: unixTimePrecision}
+	for i, t := range []*time.Time{&times.Modified, &times.Accessed, &times.Created} {
+		if flags&(1<<i) == 0 {
+			continue
+		}
+		if len(field) < 4 {
+			break
+		}
+		*t = time.Unix(int64(int32(binary.LittleEndian.Uint32(field[:4]))), 0).UTC()
+		field = field[4:]
+	}
+	return times, !times.Modified.IsZero()
+}
+
+// timeTolerance returns how far apart the modification times of an entry and
+// a file at the destination may be while still matching. It is the precision
+// of the entry's timestamp: 100ns for NTFS, a second for extended timestamps
+// and two for DOS times. Sub-second entry times are also allowed to lose their
+// fraction on destinations that only seem to keep whole seconds, as FAT and
+// HFS+ do, or hundredths of a second, as exFAT does
+func timeTolerance(entry EntryTimes, destModTime time.Time) time.Duration {
+	tolerance := entry.Precision
+	if entry.Modified.Nanosecond() == 0 {
+		return tolerance
+	}
+	switch ns := destModTime.Nanosecond(); {
+	case ns == 0:
+		tolerance = max(tolerance, dosTimePrecision)
+	case ns%int(10*time.Millisecond) == 0:
+		tolerance = max(tolerance, 10*time.Millisecond)
+	}
+	return tolerance
+}
+
+// isTimeMatch reports whether a file's modification time matches an entry's
+func isTimeMatch(f *zip.File, destModTime time.Time) bool {
+	entry := ReadEntryTimes(f)
+	return destModTime.Sub(entry.Modified).Abs() <= timeTolerance(entry, destModTime)
+}
+
+// applyEntryTimes sets the modification and access times of an extracted
+// file, and its creation time where the platform allows it
+func applyEntryTimes(path string, f *zip.File) error {
+	times := ReadEntryTimes(f)
+	accessed := times.Accessed
+	if accessed.IsZero() {
+		accessed = times.Modified
+	}
+	if err := os.Chtimes(path, accessed, times.Modified); err != nil {
+		return err
+	}
+	if !times.Created.IsZero() {
+		// Best effort, most filesystems on Linux can't set it
+		setCreationTime(path, times.Created)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package takeout
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// setCreationTime sets the birth time Finder shows as the creation date
+func setCreationTime(path string, t time.Time) error {
+	ts := unix.NsecToTimespec(t.UnixNano())
+	attrs := unix.Attrlist{Bitmapcount: unix.ATTR_BIT_MAP_COUNT, Commonattr: unix.ATTR_CMN_CRTIME}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts))
+	return unix.Setattrlist(path, &attrs, buf, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !windows
+
+package takeout
+
+import (
+	"errors"
+	"time"
+)
+
+// setCreationTime is not supported on this platform, where creation times
+// can't be set
+func setCreationTime(path string, t time.Time) error {
+	return errors.ErrUnsupported
+}
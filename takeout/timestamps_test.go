@@ -0,0 +1,136 @@
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ntfsExtra builds an NTFS extra field holding the three times, where zero
+// times are stored unset
+func ntfsExtra(modified, accessed, created time.Time) []byte {
+	ft := func(t time.Time) uint64 {
+		if t.IsZero() {
+			return 0
+		}
+		return uint64(t.UnixNano()/100) + 116444736000000000
+	}
+	field := binary.LittleEndian.AppendUint16(nil, ntfsExtraID)
+	field = binary.LittleEndian.AppendUint16(field, 32)
+	field = append(field, 0, 0, 0, 0)
+	field = binary.LittleEndian.AppendUint16(field, 1)
+	field = binary.LittleEndian.AppendUint16(field, 24)
+	for _, t := range []time.Time{modified, accessed, created} {
+		field = binary.LittleEndian.AppendUint64(field, ft(t))
+	}
+	return field
+}
+
+func TestPreciseTimestamps(t *testing.T) {
+	modified := time.Date(2023, 6, 1, 10, 30, 15, 123456700, time.UTC)
+	accessed := time.Date(2023, 6, 2, 9, 0, 0, 500000000, time.UTC)
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	zipPath := createHeaderZip(t, []*zip.FileHeader{
+		{Name: "precise.txt", Modified: modified, Extra: ntfsExtra(modified, accessed, created)},
+		{Name: "dos.txt", Modified: modified},
+	}, map[string]string{"precise.txt": "content", "dos.txt": "content"})
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	times := ReadEntryTimes(r.File[0])
+	if !times.Modified.Equal(modified) || !times.Accessed.Equal(accessed) || !times.Created.Equal(created) || times.Precision != ntfsTimePrecision {
+		t.Errorf("NTFS times = %+v", times)
+	}
+	if p := ReadEntryTimes(r.File[1]).Precision; p != unixTimePrecision {
+		t.Errorf("extended timestamp precision = %v, want %v", p, unixTimePrecision)
+	}
+
+	destDir := t.TempDir()
+	if _, err := New(Options{Destination: destDir, Workers: 1}).Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	destPath := filepath.Join(destDir, "precise.txt")
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modified) {
+		t.Errorf("extracted mtime = %v, want %v", info.ModTime().UTC(), modified)
+	}
+	if equal, reason := IsFileEqual(r.File[0], destPath); !equal {
+		t.Errorf("freshly extracted file differs: %s", reason)
+	}
+
+	// A second off passes the 2-second DOS tolerance but not NTFS precision
+	off := modified.Add(time.Second)
+	os.Chtimes(destPath, off, off)
+	if equal, _ := IsFileEqual(r.File[0], destPath); equal {
+		t.Error("file a second off matched a precise timestamp")
+	}
+}
+
+func TestTimeTolerance(t *testing.T) {
+	whole := time.Date(2023, 6, 1, 10, 30, 15, 0, time.UTC)
+	fraction := whole.Add(123456700)
+	tests := []struct {
+		name  string
+		entry EntryTimes
+		dest  time.Time
+		want  time.Duration
+	}{
+		{"NTFS", EntryTimes{Modified: fraction, Precision: ntfsTimePrecision}, fraction, ntfsTimePrecision},
+		{"NTFS on whole seconds", EntryTimes{Modified: fraction, Precision: ntfsTimePrecision}, whole, dosTimePrecision},
+		{"NTFS on hundredths", EntryTimes{Modified: fraction, Precision: ntfsTimePrecision}, whole.Add(120 * time.Millisecond), 10 * time.Millisecond},
+		{"NTFS whole second", EntryTimes{Modified: whole, Precision: ntfsTimePrecision}, whole, ntfsTimePrecision},
+		{"extended timestamp", EntryTimes{Modified: whole, Precision: unixTimePrecision}, whole, unixTimePrecision},
+		{"DOS", EntryTimes{Modified: whole, Precision: dosTimePrecision}, whole, dosTimePrecision},
+	}
+	for _, tt := range tests {
+		if got := timeTolerance(tt.entry, tt.dest); got != tt.want {
+			t.Errorf("%s: tolerance = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnsetNTFSModified(t *testing.T) {
+	wall := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	dos := &zip.FileHeader{Name: "dos.txt", Extra: ntfsExtra(time.Time{}, time.Time{}, created)}
+	dos.ModifiedDate = uint16((wall.Year()-1980)<<9 | int(wall.Month())<<5 | wall.Day())
+	dos.ModifiedTime = uint16(wall.Hour()<<11 | wall.Minute()<<5 | wall.Second()/2)
+	zipPath := createHeaderZip(t, []*zip.FileHeader{
+		dos,
+		{Name: "ext.txt", Modified: wall, Extra: ntfsExtra(time.Time{}, time.Time{}, created)},
+	}, map[string]string{"dos.txt": "dos", "ext.txt": "ext"})
+
+	destDir := t.TempDir()
+	z := New(Options{Destination: destDir, Timezone: time.UTC, Workers: 1})
+	if _, err := z.Unzip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dos.txt", "ext.txt"} {
+		info, err := os.Stat(filepath.Join(destDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(wall) {
+			t.Errorf("%s modified %v, want %v", name, info.ModTime().UTC(), wall)
+		}
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if times := ReadEntryTimes(r.File[1]); !times.Created.Equal(created) || times.Precision != unixTimePrecision {
+		t.Errorf("times with an unset NTFS modification time = %+v", times)
+	}
+}
@@ -0,0 +1,143 @@
+package takeout
+
+import (
+	"archive/zip"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimezone returns the timezone DOS timestamps in archives are read in.
+// It accepts "auto" or empty to detect it per archive, "UTC", "Local", an IANA
+// name such as Europe/Stockholm or an offset such as +02:00. Auto returns nil
+func ParseTimezone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return nil, nil
+	case "utc":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	}
+	if name[0] == '+' || name[0] == '-' {
+		digits := strings.ReplaceAll(name[1:], ":", "")
+		if len(digits) == 2 {
+			digits += "00"
+		}
+		hours, errH := strconv.Atoi(digits[:min(2, len(digits))])
+		minutes, errM := strconv.Atoi(digits[min(2, len(digits)):])
+		if len(digits) != 4 || errH != nil || errM != nil || hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("invalid timezone offset %q, want such as +02:00", name)
+		}
+		offset := (hours*60 + minutes) * 60
+		if name[0] == '-' {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// timezoneName returns the name ParseTimezone accepts for a timezone, or
+// empty for auto
+func timezoneName(zone *time.Location) string {
+	if zone == nil {
+		return ""
+	}
+	return zone.String()
+}
+
+// dosTime returns the wall clock time of an entry's DOS timestamp
+func dosTime(f *zip.File) time.Time {
+	d, t := f.ModifiedDate, f.ModifiedTime
+	return time.Date(int(d>>9)+1980, time.Month(d>>5&0xf), int(d&0x1f),
+		int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
+
+// isDOSTimeOnly reports whether an entry's only timestamp is its DOS time,
+// which has no timezone
+func isDOSTimeOnly(f *zip.File) bool {
+	return (f.ModifiedDate != 0 || f.ModifiedTime != 0) && ReadEntryTimes(f).Precision == dosTimePrecision
+}
+
+// detectTimezone works out the timezone an archive's DOS times were written
+// in, from entries that also have an extended timestamp. The local timezone
+// wins when it fits every entry, since it also accounts for daylight saving
+// time, and otherwise the most common offset. It returns nil when no entry
+// tells
+func detectTimezone(files []*zip.File) *time.Location {
+	offsets := map[time.Duration]int{}
+	localFits := true
+	for _, f := range files {
+		if f.ModifiedDate == 0 && f.ModifiedTime == 0 {
+			continue
+		}
+		times := ReadEntryTimes(f)
+		if times.Precision == dosTimePrecision {
+			continue
+		}
+		offset := dosTime(f).Sub(times.Modified.Truncate(2 * time.Second)).Round(15 * time.Minute)
+		if offset.Abs() > 14*time.Hour {
+			continue
+		}
+		offsets[offset]++
+		_, local := times.Modified.In(time.Local).Zone()
+		localFits = localFits && time.Duration(local)*time.Second == offset
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	if localFits {
+		return time.Local
+	}
+	var best time.Duration
+	for offset, n := range offsets {
+		if n > offsets[best] || n == offsets[best] && offset < best {
+			best = offset
+		}
+	}
+	return time.FixedZone(formatOffset(best), int(best.Seconds()))
+}
+
+func formatOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+// fixDOSTimes reads the DOS timestamps of entries without an extended one in
+// zone, or in the zone detected from the other entries when zone is nil,
+// falling back to UTC as Takeout writes them. It rewrites Modified in place,
+// so extraction and comparison agree, and describes the zone used, or returns
+// empty when no entry has only a DOS time
+func fixDOSTimes(files []*zip.File, zone *time.Location) string {
+	var dosOnly []*zip.File
+	for _, f := range files {
+		if isDOSTimeOnly(f) {
+			dosOnly = append(dosOnly, f)
+		}
+	}
+	if len(dosOnly) == 0 {
+		return ""
+	}
+
+	how := ""
+	if zone == nil {
+		how = " (detected)"
+		if zone = detectTimezone(files); zone == nil {
+			zone, how = time.UTC, " (default)"
+		}
+	}
+	for _, f := range dosOnly {
+		wall := dosTime(f)
+		f.Modified = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, zone)
+	}
+	return zone.String() + how
+}
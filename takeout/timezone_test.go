@@ -0,0 +1,80 @@
+package takeout
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTimezone(t *testing.T) {
+	for name, offset := range map[string]int{"UTC": 0, "+02:00": 7200, "-0530": -19800, "+09": 32400} {
+		zone, err := ParseTimezone(name)
+		if err != nil {
+			t.Errorf("ParseTimezone(%q): %v", name, err)
+			continue
+		}
+		if _, got := time.Date(2024, 1, 1, 0, 0, 0, 0, zone).Zone(); got != offset {
+			t.Errorf("ParseTimezone(%q) offset = %d, want %d", name, got, offset)
+		}
+	}
+	if zone, err := ParseTimezone("auto"); zone != nil || err != nil {
+		t.Errorf("ParseTimezone(auto) = %v, %v; want nil", zone, err)
+	}
+	for _, name := range []string{"+25:00", "Mars/Olympus"} {
+		if _, err := ParseTimezone(name); err == nil {
+			t.Errorf("ParseTimezone(%q) should fail", name)
+		}
+	}
+}
+
+// createZoneZip writes an entry with a precise timestamp in zone and one with
+// only a DOS timestamp of wall
+func createZoneZip(t *testing.T, zone *time.Location, wall time.Time) string {
+	t.Helper()
+	dos := &zip.FileHeader{Name: "dos.txt"}
+	dos.ModifiedDate = uint16((wall.Year()-1980)<<9 | int(wall.Month())<<5 | wall.Day())
+	dos.ModifiedTime = uint16(wall.Hour()<<11 | wall.Minute()<<5 | wall.Second()/2)
+	return createHeaderZip(t, []*zip.FileHeader{
+		{Name: "precise.txt", Modified: time.Date(2023, 5, 1, 9, 0, 0, 0, zone)},
+		dos,
+	}, map[string]string{"precise.txt": "precise", "dos.txt": "dos"})
+}
+
+func TestDOSTimezone(t *testing.T) {
+	wall := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	zipPath := createZoneZip(t, time.FixedZone("", 2*3600), wall)
+
+	tests := []struct {
+		zone *time.Location
+		want time.Time
+	}{
+		{nil, time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{time.UTC, wall},
+		{time.FixedZone("", -3600), time.Date(2023, 6, 1, 13, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		destDir := t.TempDir()
+		z := New(Options{Destination: destDir, Timezone: tt.zone, Workers: 1})
+		if _, err := z.Unzip(zipPath); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filepath.Join(destDir, "dos.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(tt.want) {
+			t.Errorf("timezone %v: dos.txt modified %v, want %v", tt.zone, info.ModTime().UTC(), tt.want)
+		}
+
+		// Comparison reads the times the same way
+		result, err := New(Options{Destination: destDir, Timezone: tt.zone, Workers: 1}).Unzip(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Skipped != 2 {
+			t.Errorf("timezone %v: rerun skipped %d files, want 2", tt.zone, result.Skipped)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// byteRate is a flag value for rates such as 50MB/s. Zero means unlimited
+type byteRate byteSize
+
+func (r *byteRate) String() string {
+	if *r <= 0 {
+		return ""
+	}
+	return formatSize(int64(*r)) + "/s"
+}
+
+func (r *byteRate) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(strings.ToLower(value), "/s") {
+		value = value[:len(value)-2]
+	}
+	if err := (*byteSize)(r).Set(value); err != nil {
+		return fmt.Errorf("invalid rate %q, want such as 50MB/s", value)
+	}
+	return nil
+}
+
+// scheduleFlag is a flag value for a daily time range such as 08:00-18:00
+type scheduleFlag struct {
+	window *takeout.DailyWindow
+}
+
+func (s *scheduleFlag) String() string {
+	if s.window == nil {
+		return ""
+	}
+	return s.window.String()
+}
+
+func (s *scheduleFlag) Set(value string) error {
+	w, err := takeout.ParseDailyWindow(value)
+	if err != nil {
+		return err
+	}
+	s.window = &w
+	return nil
+}
+
+// addThrottleFlags registers the flags limiting how fast files are written
+func addThrottleFlags(flags *flag.FlagSet, opts *jobOptions) {
+	flags.Var(&opts.maxRate, "max-rate", "Write at most this much per second, such as 50MB/s")
+	flags.Float64Var(&opts.maxFileRate, "max-file-rate", 0, "Write at most this many files per second")
+	flags.Var(&opts.throttleHours, "throttle-hours", "Only apply --max-rate and --max-file-rate within this daily time range, such as 08:00-18:00")
+}
+
+// newThrottle returns the throttle of the job, or nil when there are no
+// limits
+func (opts *jobOptions) newThrottle() *takeout.Throttle {
+	if opts.maxRate <= 0 && opts.maxFileRate <= 0 {
+		return nil
+	}
+	t := takeout.NewThrottle(float64(max(opts.maxRate, 0)), max(opts.maxFileRate, 0))
+	if opts.throttleHours.window != nil {
+		t.SetSchedule(*opts.throttleHours.window)
+	}
+	return t
+}
+
+// describeThrottle describes the limits of a throttle and when they apply
+func describeThrottle(t *takeout.Throttle, opts *jobOptions) string {
+	maxRate, maxFiles := t.Limits()
+	var limits []string
+	if maxRate > 0 {
+		limits = append(limits, formatSize(int64(maxRate))+"/s")
+	}
+	if maxFiles > 0 {
+		limits = append(limits, fmt.Sprintf("%.4g files/s", maxFiles))
+	}
+	s := strings.Join(limits, " and ")
+	if w := opts.throttleHours.window; w != nil {
+		s += fmt.Sprintf(" from %s", strings.Replace(w.String(), "-", " to ", 1))
+	}
+	return s
+}
+
+// startThrottle announces the limits of a throttle and lets signals change
+// them while extraction runs
+func startThrottle(t *takeout.Throttle, opts *jobOptions) {
+	if t == nil || opts.dryRun {
+		return
+	}
+	fmt.Printf("\n🐢 Throttled to %s\n", describeThrottle(t, opts))
+	watchThrottleSignals(t, func() string { return describeThrottle(t, opts) })
+}
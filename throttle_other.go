@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "github.com/viclarsson/unzip-takeout/takeout"
+
+// watchThrottleSignals does nothing on platforms without SIGUSR1 and SIGUSR2,
+// where the limits stay as given
+func watchThrottleSignals(t *takeout.Throttle, describe func() string) {}
@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/viclarsson/unzip-takeout/takeout"
+)
+
+// watchThrottleSignals halves the limits of a throttle on SIGUSR1 and doubles
+// them on SIGUSR2
+func watchThrottleSignals(t *takeout.Throttle, describe func() string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				t.Scale(0.5)
+			} else {
+				t.Scale(2)
+			}
+			fmt.Printf("\n🐢 Throttled to %s\n", describe())
+		}
+	}()
+}